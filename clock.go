@@ -0,0 +1,150 @@
+package rdx
+
+import (
+	"errors"
+	"sync"
+)
+
+// ClockStore persists the clock so a restarted replica never reuses
+// a stamp. The clock saves a reservation: a seq that is not issued yet;
+// on restart, it continues from the saved value.
+type ClockStore interface {
+	Load() (seq uint64, err error)
+	Save(seq uint64) error
+}
+
+// ClockReserve is how far ahead (in seq units) the clock reserves
+// stamps in its store, so it does not save on every Next().
+const ClockReserve = 1 << 16
+
+var ErrClockOverflow = errors.New("clock overflow")
+
+// Clock hands out monotonic stamps for a replica. By default it is a
+// hybrid logical clock: a new stamp is the wall clock Timestamp() or,
+// if that is behind, the last stamp issued or seen plus one. Stamps
+// have zero revision bits. A Lamport clock does not use the wall time.
+// The zero value (or a literal with just Src set) is a hybrid clock too.
+type Clock struct {
+	Src uint64
+
+	mx      sync.Mutex
+	last    uint64
+	saved   uint64
+	store   ClockStore
+	lamport bool
+	now     func() uint64
+	err     error
+}
+
+func NewClock(src uint64) *Clock {
+	return &Clock{Src: src, now: Timestamp}
+}
+
+func NewLamportClock(src uint64) *Clock {
+	return &Clock{Src: src, lamport: true}
+}
+
+// Persist loads the clock state from the store and keeps saving
+// reservations there.
+func (c *Clock) Persist(store ClockStore) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	seq, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if seq > c.last {
+		c.last = seq
+	}
+	c.saved = c.last
+	c.store = store
+	return nil
+}
+
+// Err returns the store error, if any; the clock stops issuing
+// stamps once it can not save a reservation.
+func (c *Clock) Err() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.err
+}
+
+// Last returns the last issued or seen stamp.
+func (c *Clock) Last() ID {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return ID{c.Src, c.last}
+}
+
+func (c *Clock) tick(n uint64) (first uint64) {
+	if c.err != nil {
+		return 0
+	}
+	first = (c.last & MaskNoRev) + 64
+	if !c.lamport {
+		now := c.now
+		if now == nil {
+			now = Timestamp
+		}
+		if wall := now() & MaskNoRev; wall > first {
+			first = wall
+		}
+	}
+	last := first + (n-1)*64
+	if last > Mask60bit || last < first {
+		c.err = ErrClockOverflow
+		return 0
+	}
+	if c.store != nil && last > c.saved {
+		saved := min(last+ClockReserve, Mask60bit)
+		if c.err = c.store.Save(saved); c.err != nil {
+			return 0
+		}
+		c.saved = saved
+	}
+	c.last = last
+	return
+}
+
+// Next returns a new stamp; returns ID0 if the clock failed, see Err().
+func (c *Clock) Next() ID {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	seq := c.tick(1)
+	if seq == 0 {
+		return ID0
+	}
+	return ID{c.Src, seq}
+}
+
+// NextN reserves n consecutive stamps, e.g. for an insert train,
+// and returns the first one; the rest are first.Seq + 64*i.
+// Returns ID0 if the clock failed, see Err().
+func (c *Clock) NextN(n int) ID {
+	if n <= 0 {
+		return ID0
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	seq := c.tick(uint64(n))
+	if seq == 0 {
+		return ID0
+	}
+	return ID{c.Src, seq}
+}
+
+// See advances the clock past a stamp, e.g. one from a remote patch.
+func (c *Clock) See(id ID) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if seq := id.Seq & MaskNoRev; seq > c.last && seq <= Mask60bit {
+		c.last = seq
+	}
+}
+
+// SeeAll advances the clock past all the stamps in a document.
+func (c *Clock) SeeAll(rdx Stream) {
+	for _, seq := range VersionOf(rdx) {
+		c.See(ID{Seq: seq})
+	}
+}
@@ -0,0 +1,83 @@
+package rdx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClockStore struct {
+	seq   uint64
+	saves int
+	err   error
+}
+
+func (s *testClockStore) Load() (uint64, error) {
+	return s.seq, nil
+}
+
+func (s *testClockStore) Save(seq uint64) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.seq = seq
+	s.saves++
+	return nil
+}
+
+func TestLamportClock(t *testing.T) {
+	c := NewLamportClock(7)
+	assert.Equal(t, ID{7, 64}, c.Next())
+	assert.Equal(t, ID{7, 128}, c.Next())
+	first := c.NextN(3)
+	assert.Equal(t, ID{7, 192}, first)
+	assert.Equal(t, ID{7, 320}, c.Last())
+	c.See(ID{9, 1000})
+	assert.Equal(t, ID{7, 1024}, c.Next())
+	c.See(ID{9, 10})
+	assert.Equal(t, ID{7, 1088}, c.Next())
+	assert.Equal(t, ID0, c.NextN(0))
+}
+
+func TestHybridClock(t *testing.T) {
+	wall := uint64(1 << 40)
+	c := NewClock(1)
+	c.now = func() uint64 { return wall }
+	assert.Equal(t, ID{1, wall}, c.Next())
+	assert.Equal(t, ID{1, wall + 64}, c.Next())
+	wall += 1 << 20
+	assert.Equal(t, ID{1, wall}, c.Next())
+	c.See(ID{2, wall + 1000})
+	assert.Equal(t, (wall+1000)&MaskNoRev+64, c.Next().Seq)
+
+	hlc := NewClock(1)
+	a, b := hlc.Next(), hlc.Next()
+	assert.Equal(t, Less, a.Compare(b))
+	assert.Zero(t, a.Rev())
+
+	lit := &Clock{Src: 5}
+	a, b = lit.Next(), lit.Next()
+	assert.Equal(t, uint64(5), a.Src)
+	assert.Equal(t, Less, a.Compare(b))
+	assert.Nil(t, lit.Err())
+}
+
+func TestClockPersist(t *testing.T) {
+	store := &testClockStore{}
+	c := NewLamportClock(1)
+	assert.Nil(t, c.Persist(store))
+	var last ID
+	for i := 0; i < 3000; i++ {
+		last = c.Next()
+	}
+	assert.Less(t, store.saves, 5)
+	c2 := NewLamportClock(1)
+	assert.Nil(t, c2.Persist(store))
+	assert.Equal(t, Less, last.Compare(c2.Next()))
+
+	store.err = errors.New("disk full")
+	c2.See(ID{2, store.seq + 64})
+	assert.Equal(t, ID0, c2.Next())
+	assert.Equal(t, store.err, c2.Err())
+}
@@ -0,0 +1,265 @@
+// Command rdx converts, merges and inspects RDX documents.
+//
+//	rdx parse [file...]          JDR text to binary RDX
+//	rdx render [flags] [file...] binary RDX to JDR text
+//	rdx merge file...            merge N binary RDX documents into one
+//	rdx normalize [file...]      normalize binary RDX
+//	rdx flatten [file...]        strip metadata and tombstones
+//	rdx delve path [file...]     pick an element by a JDR path, e.g. "1 0"
+//
+// Input is read from the files listed or from stdin, named "-" at most once;
+// output goes to stdout.
+// The -j flag makes merge, normalize, flatten and delve read and write JDR.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gritzko/rdx"
+)
+
+const (
+	exitOK    = 0
+	exitFail  = 1
+	exitUsage = 2
+)
+
+var (
+	errUnknownCommand = errors.New("unknown command")
+	errStdinTwice     = errors.New("stdin (-) may be named once")
+	errBadInput       = errors.New("malformed binary RDX")
+)
+
+const usage = `usage: rdx <command> [flags] [file...]
+commands: parse, render, merge, normalize, flatten, delve <path>
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type command struct {
+	flags  *flag.FlagSet
+	jdr    *bool
+	binary bool // the input is binary RDX, unless -j
+	styles map[string]*bool
+}
+
+var styleFlags = []struct {
+	name string
+	bit  rdx.Style
+	help string
+}{
+	{"stamps", rdx.StyleStamps, "render stamps"},
+	{"comma", rdx.StyleUseComma, "separate elements with commas"},
+	{"lf", rdx.StyleUseLF, "break lines"},
+	{"tab", rdx.StyleIndentTab, "indent with tabs"},
+	{"space4", rdx.StyleIndentSpace4, "indent with 4 spaces"},
+	{"trailing", rdx.StyleTrailingComma, "put trailing commas"},
+	{"inline", rdx.StyleShortInlineTuples, "use colon notation for short tuples"},
+	{"yell", rdx.StyleYell, "use semicolon notation for tagged tuples"},
+}
+
+func newCommand(name string, stderr io.Writer) (cmd *command) {
+	cmd = &command{flags: flag.NewFlagSet(name, flag.ContinueOnError)}
+	cmd.flags.SetOutput(stderr)
+	cmd.binary = name != "parse"
+	switch name {
+	case "render":
+		cmd.styles = make(map[string]*bool)
+		for _, s := range styleFlags {
+			cmd.styles[s.name] = cmd.flags.Bool(s.name, false, s.help)
+		}
+		cmd.styles["normal"] = cmd.flags.Bool("normal", false, "use the normal multiline style")
+	case "merge", "normalize", "flatten", "delve":
+		cmd.jdr = cmd.flags.Bool("j", false, "read and write JDR text")
+	}
+	return
+}
+
+func (cmd *command) Style() (style rdx.Style) {
+	if *cmd.styles["normal"] {
+		style = rdx.JDRNormalStyle
+	}
+	for _, s := range styleFlags {
+		if *cmd.styles[s.name] {
+			style.Add(s.bit)
+		}
+	}
+	return
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	name := args[0]
+	cmd := newCommand(name, stderr)
+	if err := cmd.flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	files := cmd.flags.Args()
+	var out []byte
+	var err error
+	switch name {
+	case "parse":
+		out, err = cmd.each(files, stdin, rdx.ParseJDR)
+	case "render":
+		style := cmd.Style()
+		out, err = cmd.each(files, stdin, func(data []byte) ([]byte, error) {
+			jdr := rdx.RenderJDR(data, style)
+			if len(jdr) > 0 && jdr[len(jdr)-1] != '\n' {
+				jdr = append(jdr, '\n')
+			}
+			return jdr, nil
+		})
+	case "normalize":
+		out, err = cmd.each(files, stdin, rdx.Normalize)
+	case "flatten":
+		out, err = cmd.each(files, stdin, func(data []byte) ([]byte, error) {
+			return rdx.Flatten(nil, data)
+		})
+	case "merge":
+		out, err = cmd.merge(files, stdin)
+	case "delve":
+		if len(files) == 0 {
+			_, _ = fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		var path []byte
+		path, err = rdx.ParseJDR([]byte(files[0]))
+		if err == nil {
+			out, err = cmd.each(files[1:], stdin, func(data []byte) ([]byte, error) {
+				return rdx.Delve(data, path)
+			})
+		}
+	case "help", "-h", "-help", "--help":
+		_, _ = fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		err = errUnknownCommand
+	}
+	if err == nil {
+		_, err = stdout.Write(out)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "rdx %s: %s\n", name, err.Error())
+		if err == errUnknownCommand {
+			_, _ = fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		return exitFail
+	}
+	return exitOK
+}
+
+func readInputs(files []string, stdin io.Reader) (inputs [][]byte, err error) {
+	if len(files) == 0 {
+		var data []byte
+		data, err = io.ReadAll(stdin)
+		return [][]byte{data}, err
+	}
+	stdins := 0
+	for _, file := range files {
+		var data []byte
+		if file == "-" {
+			if stdins++; stdins > 1 {
+				return nil, errStdinTwice
+			}
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, data)
+	}
+	return
+}
+
+func (cmd *command) decode(data []byte) ([]byte, error) {
+	if cmd.jdr != nil && *cmd.jdr {
+		return rdx.ParseNormalizeJDR(data)
+	}
+	if cmd.binary {
+		if err := checkTLV(data, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// checkTLV checks the record structure of binary input: the library
+// takes the lengths on trust, so a malformed input may go unnoticed.
+// Unlike rdx.Validate, it accepts input that is not normalized.
+// The offset is that of the data in the input, for errors.
+func checkTLV(data []byte, offset, depth int) error {
+	if depth > rdx.MaxNesting {
+		return fmt.Errorf("%w at offset %d: %w", errBadInput, offset, rdx.ErrBadNesting)
+	}
+	for pos := 0; pos < len(data); {
+		lit, val, rest, err := rdx.ReadTLV(data[pos:])
+		switch {
+		case err != nil:
+		case !rdx.IsFIRST(lit) && !rdx.IsPLEX(lit):
+			err = rdx.ErrBadRecord
+		case len(val) == 0 || int(val[0]) >= len(val):
+			err = rdx.ErrBadRecord
+		case rdx.IsPLEX(lit):
+			body := val[1+int(val[0]):]
+			inner := offset + len(data) - len(rest) - len(body)
+			if err = checkTLV(body, inner, depth+1); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%w at offset %d: %w", errBadInput, offset+pos, err)
+		}
+		pos = len(data) - len(rest)
+	}
+	return nil
+}
+
+func (cmd *command) encode(data []byte) ([]byte, error) {
+	if cmd.jdr != nil && *cmd.jdr {
+		jdr := rdx.RenderJDR(data, 0)
+		return append(jdr, '\n'), nil
+	}
+	return data, nil
+}
+
+func (cmd *command) each(files []string, stdin io.Reader, fn func([]byte) ([]byte, error)) (out []byte, err error) {
+	inputs, err := readInputs(files, stdin)
+	for i := 0; i < len(inputs) && err == nil; i++ {
+		var data, res []byte
+		data, err = cmd.decode(inputs[i])
+		if err == nil {
+			res, err = fn(data)
+		}
+		if err == nil {
+			res, err = cmd.encode(res)
+		}
+		out = append(out, res...)
+	}
+	return
+}
+
+func (cmd *command) merge(files []string, stdin io.Reader) (out []byte, err error) {
+	inputs, err := readInputs(files, stdin)
+	for i := 0; i < len(inputs) && err == nil; i++ {
+		inputs[i], err = cmd.decode(inputs[i])
+	}
+	if err == nil {
+		out, err = rdx.Merge(nil, inputs)
+	}
+	if err == nil {
+		out, err = cmd.encode(out)
+	}
+	return
+}
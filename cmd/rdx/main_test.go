@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rdxRun(args []string, stdin string) (code int, out, errs string) {
+	var stdout, stderr bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestParseRender(t *testing.T) {
+	code, bin, _ := rdxRun([]string{"parse"}, "{a:1, b:[2 3]}")
+	assert.Equal(t, exitOK, code)
+	code, jdr, _ := rdxRun([]string{"render", "-comma", "-inline"}, bin)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "{a:1,(b,[2,3])}\n", jdr)
+}
+
+func TestMergeJDR(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.jdr"), filepath.Join(dir, "b.jdr")
+	assert.Nil(t, os.WriteFile(a, []byte("{1 2} x"), 0o644))
+	assert.Nil(t, os.WriteFile(b, []byte("{3 2} y@bob-2"), 0o644))
+	code, jdr, _ := rdxRun([]string{"merge", "-j", a, b}, "")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "{1 2 3} y@bob-2\n", jdr)
+	code, jdr, _ = rdxRun([]string{"merge", "-j", a, "-"}, "{4}")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "{1 2 4} x\n", jdr)
+	code, _, errs := rdxRun([]string{"merge", "-j", "-", "-"}, "{1 2}")
+	assert.Equal(t, exitFail, code)
+	assert.Contains(t, errs, errStdinTwice.Error())
+	code, jdr, _ = rdxRun([]string{"normalize", "-j"}, "{3 1 2 1}")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "{1 2 3}\n", jdr)
+	code, jdr, _ = rdxRun([]string{"delve", "-j", "0 1"}, "(1 (2 3))")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "(2 3)\n", jdr)
+}
+
+func TestErrors(t *testing.T) {
+	code, _, _ := rdxRun(nil, "")
+	assert.Equal(t, exitUsage, code)
+	code, _, _ = rdxRun([]string{"frobnicate"}, "")
+	assert.Equal(t, exitUsage, code)
+	code, _, errs := rdxRun([]string{"parse"}, "{1 2")
+	assert.Equal(t, exitFail, code)
+	assert.NotEmpty(t, errs)
+
+	// malformed binary input fails instead of hanging or printing nothing
+	bad := []string{"\xff\xfe\x01garbage", "i\x09", "p\x00", "P\xff\xff\x00\x00\x00"}
+	for _, in := range bad {
+		for _, cmd := range []string{"render", "normalize", "flatten", "merge"} {
+			code, out, errs := rdxRun([]string{cmd}, in)
+			assert.Equal(t, exitFail, code, cmd+" %q", in)
+			assert.Empty(t, out)
+			assert.Contains(t, errs, errBadInput.Error())
+		}
+	}
+	code, _, errs = rdxRun([]string{"render"}, "p\x04\x00i\x09\x00")
+	assert.Equal(t, exitFail, code)
+	assert.Contains(t, errs, "offset 3")
+}
@@ -0,0 +1,262 @@
+package rdx
+
+import (
+	"errors"
+	"math"
+)
+
+// Counters and registers are Multix elements, one contribution per
+// replica, see y.X.md. A replica only ever changes its own contribution,
+// stamping it with a fresh clock value, so the Multix merge picks the
+// latest contribution of every replica. A patch is a Multix with the
+// same ID holding the one changed contribution.
+
+var (
+	ErrBadCounter      = errors.New("bad counter")
+	ErrCounterOverflow = errors.New("counter overflow")
+	ErrNegativeDelta   = errors.New("a counter can not decrease, use PNCounter")
+)
+
+// Counter is a grow-only counter: <3@alice-2 5@bob-4> is 8.
+type Counter Stream
+
+// PNCounter is a counter that may go down: a tuple of two grow-only
+// counters, the increments and the decrements, (<5@alice-3> <2@bob-2>).
+type PNCounter Stream
+
+// FloatCounter is a counter of Float contributions; it may go either way.
+type FloatCounter Stream
+
+// MaxRegister holds the maximum value ever set by any replica.
+type MaxRegister Stream
+
+// MinRegister holds the minimum value ever set by any replica.
+type MinRegister Stream
+
+// multixOf returns the ID of a Multix and the live contribution
+// of the source, if any; an empty stream is an empty Multix
+func multixOf(rdx Stream, src uint64) (id ID, own Iter, err error) {
+	if len(rdx) == 0 {
+		return
+	}
+	it := NewIter(rdx)
+	if !it.Read() || it.Lit() != LitMultix {
+		return ID0, Iter{}, ErrBadCounter
+	}
+	in := it.Inner()
+	for in.Read() {
+		if in.ID().Src == src && in.IsLive() {
+			own = in
+		}
+	}
+	if in.HasFailed() {
+		return ID0, Iter{}, in.Error()
+	}
+	return it.ID(), own, nil
+}
+
+// contribute makes a patch setting the contribution of the replica
+func contribute(id ID, own *Iter, clock *Clock, lit byte, val []byte) (patch Stream, err error) {
+	if own.HasData() {
+		clock.See(own.ID())
+	}
+	stamp := clock.Next()
+	if stamp == ID0 {
+		return nil, clock.Err()
+	}
+	patch = WriteRDX(nil, lit, stamp, val)
+	return WriteRDX(nil, LitMultix, id, patch), nil
+}
+
+// eachContribution calls f for every live contribution
+func eachContribution(rdx Stream, lit byte, f func(in *Iter)) error {
+	if len(rdx) == 0 {
+		return nil
+	}
+	it := NewIter(rdx)
+	if !it.Read() || it.Lit() != LitMultix {
+		return ErrBadCounter
+	}
+	in := it.Inner()
+	for in.Read() {
+		if !in.IsLive() {
+			continue
+		}
+		if in.Lit() != lit {
+			return ErrBadCounter
+		}
+		f(&in)
+	}
+	if in.HasFailed() {
+		return in.Error()
+	}
+	return nil
+}
+
+// Inc returns a patch adding delta to the counter.
+func (c Counter) Inc(clock *Clock, delta int64) (patch Stream, err error) {
+	if delta < 0 {
+		return nil, ErrNegativeDelta
+	}
+	id, own, err := multixOf(Stream(c), clock.Src)
+	if err != nil {
+		return nil, err
+	}
+	val := int64(0)
+	if own.HasData() {
+		if own.Lit() != LitInteger {
+			return nil, ErrBadCounter
+		}
+		val = int64(own.Integer())
+	}
+	if val+delta < val {
+		return nil, ErrCounterOverflow
+	}
+	return contribute(id, &own, clock, LitInteger, ZipInt64(val+delta))
+}
+
+// Value sums the contributions.
+func (c Counter) Value() (sum int64, err error) {
+	err = eachContribution(Stream(c), LitInteger, func(in *Iter) {
+		sum += int64(in.Integer())
+	})
+	return
+}
+
+// halves returns the increments and the decrements of a PN counter
+func (c PNCounter) halves() (id ID, inc, dec Stream, err error) {
+	if len(c) == 0 {
+		return
+	}
+	it := NewIter(Stream(c))
+	if !it.Read() || it.Lit() != LitTuple {
+		return ID0, nil, nil, ErrBadCounter
+	}
+	in := it.Inner()
+	if in.Read() && !IsEmptyTuple(&in) {
+		inc = in.Record()
+	}
+	if in.Read() && !IsEmptyTuple(&in) {
+		dec = in.Record()
+	}
+	if in.HasFailed() {
+		return ID0, nil, nil, in.Error()
+	}
+	return it.ID(), inc, dec, nil
+}
+
+// Inc returns a patch adding delta to the counter; delta may be negative.
+func (c PNCounter) Inc(clock *Clock, delta int64) (patch Stream, err error) {
+	id, inc, dec, err := c.halves()
+	if err != nil {
+		return nil, err
+	}
+	var half Stream
+	if delta >= 0 {
+		half, err = Counter(inc).Inc(clock, delta)
+		inc, dec = half, RDXEmptyTuple
+	} else if delta != math.MinInt64 {
+		half, err = Counter(dec).Inc(clock, -delta)
+		inc, dec = RDXEmptyTuple, half
+	} else {
+		err = ErrCounterOverflow
+	}
+	if err != nil {
+		return nil, err
+	}
+	return WriteRDX(nil, LitTuple, id, append(append(Stream{}, inc...), dec...)), nil
+}
+
+// Value returns the increments minus the decrements.
+func (c PNCounter) Value() (val int64, err error) {
+	_, inc, dec, err := c.halves()
+	if err != nil {
+		return 0, err
+	}
+	plus, err := Counter(inc).Value()
+	if err != nil {
+		return 0, err
+	}
+	minus, err := Counter(dec).Value()
+	return plus - minus, err
+}
+
+// Inc returns a patch adding delta to the counter.
+func (c FloatCounter) Inc(clock *Clock, delta float64) (patch Stream, err error) {
+	if math.IsNaN(delta) {
+		return nil, ErrBadFloatRecord
+	}
+	id, own, err := multixOf(Stream(c), clock.Src)
+	if err != nil {
+		return nil, err
+	}
+	val := float64(0)
+	if own.HasData() {
+		if own.Lit() != LitFloat {
+			return nil, ErrBadCounter
+		}
+		val = float64(own.Float())
+	}
+	return contribute(id, &own, clock, LitFloat, ZipFloat64(val+delta))
+}
+
+// Value sums the contributions.
+func (c FloatCounter) Value() (sum float64, err error) {
+	err = eachContribution(Stream(c), LitFloat, func(in *Iter) {
+		sum += float64(in.Float())
+	})
+	return
+}
+
+// registerValue returns the maximum (or the minimum) of the contributions
+func registerValue(rdx Stream, less bool) (val int64, err error) {
+	n := 0
+	err = eachContribution(rdx, LitInteger, func(in *Iter) {
+		v := int64(in.Integer())
+		if n == 0 || (v < val) == less && v != val {
+			val = v
+		}
+		n++
+	})
+	if err == nil && n == 0 {
+		err = ErrRecordNotFound
+	}
+	return
+}
+
+// registerSet makes a patch if the value changes the register
+func registerSet(rdx Stream, clock *Clock, val int64, less bool) (patch Stream, err error) {
+	cur, err := registerValue(rdx, less)
+	if err == nil && (cur == val || (val < cur) != less) {
+		return nil, nil
+	} else if err != nil && err != ErrRecordNotFound {
+		return nil, err
+	}
+	id, own, err := multixOf(rdx, clock.Src)
+	if err != nil {
+		return nil, err
+	}
+	return contribute(id, &own, clock, LitInteger, ZipInt64(val))
+}
+
+// Value returns the maximum; ErrRecordNotFound if nothing was set.
+func (r MaxRegister) Value() (int64, error) {
+	return registerValue(Stream(r), false)
+}
+
+// Set returns a patch raising the register to the value;
+// nil if the register is already that high.
+func (r MaxRegister) Set(clock *Clock, val int64) (patch Stream, err error) {
+	return registerSet(Stream(r), clock, val, false)
+}
+
+// Value returns the minimum; ErrRecordNotFound if nothing was set.
+func (r MinRegister) Value() (int64, error) {
+	return registerValue(Stream(r), true)
+}
+
+// Set returns a patch lowering the register to the value;
+// nil if the register is already that low.
+func (r MinRegister) Set(clock *Clock, val int64) (patch Stream, err error) {
+	return registerSet(Stream(r), clock, val, true)
+}
@@ -0,0 +1,79 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	alice, bob := NewLamportClock(1), NewLamportClock(2)
+	var doc Stream
+	apply := func(patch Stream, err error) {
+		assert.Nil(t, err)
+		doc, err = Merge(nil, [][]byte{doc, patch})
+		assert.Nil(t, err)
+	}
+	apply(Counter(doc).Inc(alice, 3))
+	p1, err := Counter(doc).Inc(alice, 2)
+	assert.Nil(t, err)
+	p2, err := Counter(doc).Inc(bob, 5)
+	assert.Nil(t, err)
+	apply(p2, nil)
+	apply(p1, nil)
+	apply(p1, nil)
+	val, err := Counter(doc).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), val)
+	_, err = Counter(doc).Inc(bob, -1)
+	assert.Equal(t, ErrNegativeDelta, err)
+	_, err = Counter(I0(1)).Value()
+	assert.Equal(t, ErrBadCounter, err)
+
+	doc = nil
+	apply(PNCounter(doc).Inc(alice, 7))
+	apply(PNCounter(doc).Inc(bob, -3))
+	apply(PNCounter(doc).Inc(alice, -1))
+	apply(PNCounter(doc).Inc(bob, 2))
+	pn, err := PNCounter(doc).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), pn)
+
+	doc = nil
+	apply(FloatCounter(doc).Inc(alice, 1.5))
+	apply(FloatCounter(doc).Inc(bob, -0.25))
+	apply(FloatCounter(doc).Inc(alice, 1))
+	fl, err := FloatCounter(doc).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, 2.25, fl)
+}
+
+func TestRegisters(t *testing.T) {
+	alice, bob := NewLamportClock(1), NewLamportClock(2)
+	_, err := MaxRegister(nil).Value()
+	assert.Equal(t, ErrRecordNotFound, err)
+	var mx, mn Stream
+	for i, v := range []int64{3, -1, 7, 5} {
+		clock := alice
+		if i%2 == 1 {
+			clock = bob
+		}
+		patch, err := MaxRegister(mx).Set(clock, v)
+		assert.Nil(t, err)
+		mx, err = Merge(nil, [][]byte{mx, patch})
+		assert.Nil(t, err)
+		patch, err = MinRegister(mn).Set(clock, v)
+		assert.Nil(t, err)
+		mn, err = Merge(nil, [][]byte{mn, patch})
+		assert.Nil(t, err)
+	}
+	val, err := MaxRegister(mx).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), val)
+	val, err = MinRegister(mn).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), val)
+	patch, err := MaxRegister(mx).Set(bob, 6)
+	assert.Nil(t, err)
+	assert.Nil(t, patch)
+}
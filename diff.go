@@ -0,0 +1,234 @@
+package rdx
+
+import (
+	"bytes"
+	"errors"
+)
+
+var ErrRevisionOverflow = errors.New("revision overflow")
+
+// Diff produces a patch D so that |A + D| = |B|, see the Diffing/Patching
+// invariant. Both inputs must be normalized. Unchanged elements are skipped,
+// removed ones are tombstoned, changed ones get stamps that win the merge.
+// If B outranks A (its changes have winning stamps, its removals are
+// tombstones), A + D equals B, metadata included. Otherwise, the patch
+// carries stamps minted to win (see outrank), so A + D equals B once
+// flattened, while its stamps differ from those of B.
+func Diff(a, b Stream) (patch Stream, err error) {
+	return diffTuple(nil, a, b, false)
+}
+
+// outrank picks a stamp for `want` that beats `old` in the LWW order while
+// keeping the parity (liveness) of `want`; same reports whether the base
+// (identity) part of `want` survived the bump.
+func outrank(old, want ID) (id ID, same bool) {
+	if want.RevCompare(old) > Eq {
+		return want, true
+	}
+	id = ID{want.Src, old.Seq}
+	if id.Src <= old.Src || (id.Seq&1) != (want.Seq&1) {
+		id.Seq++
+		if (id.Seq & 1) != (want.Seq & 1) {
+			id.Seq++
+		}
+	}
+	same = id.Base() == want.Base()
+	return
+}
+
+func appendTombstone(data []byte, x *Iter, plit byte) []byte {
+	if !x.IsLive() {
+		return data
+	}
+	id := x.ID().Removed()
+	lit := x.Lit()
+	if !IsPLEX(lit) {
+		return WriteRDX(data, lit, id, x.Value())
+	}
+	var key []byte
+	if lit == LitTuple && plit == LitEuler {
+		in := x.Inner()
+		if in.Read() {
+			key = in.Record()
+		}
+	}
+	return WriteRDX(data, lit, id, key)
+}
+
+// diffElement appends the patch for two same-spot elements; appends
+// nothing if those are identical
+func diffElement(data []byte, a, b *Iter, plit byte) (patch []byte, err error) {
+	patch = data
+	if bytes.Equal(a.Record(), b.Record()) {
+		return
+	}
+	lit := b.Lit()
+	if IsPLEX(lit) && IsSame(a, b) {
+		id, same := b.ID(), true
+		if id.RevCompare(a.ID()) < Eq {
+			id, same = outrank(a.ID(), id)
+		}
+		if same {
+			stack := make(Marks, 0, 1)
+			patch = OpenTLV(patch, lit, &stack)
+			zip := ZipID(id)
+			patch = append(patch, byte(len(zip)))
+			patch = append(patch, zip...)
+			l := len(patch)
+			keyed := lit == LitTuple && plit == LitEuler
+			patch, err = diffContents(patch, a.Value(), b.Value(), lit, keyed)
+			if err != nil {
+				return
+			}
+			if len(patch) == l && id == a.ID() {
+				patch, err = CancelTLV(patch, lit, &stack)
+			} else {
+				patch, err = CloseTLV(patch, lit, &stack)
+			}
+			return
+		}
+	}
+	if CompareLWW(a, b) < Eq {
+		return append(patch, b.Record()...), nil
+	}
+	id, same := outrank(a.ID(), b.ID())
+	if plit == LitLinear && !same {
+		return nil, ErrRevisionOverflow
+	}
+	return WriteRDX(patch, lit, id, b.Value()), nil
+}
+
+func diffContents(data, a, b []byte, lit byte, keyed bool) (patch []byte, err error) {
+	switch lit {
+	case LitTuple:
+		patch, err = diffTuple(data, a, b, keyed)
+	case LitLinear:
+		context := !isAscending(a, CompareLinear) || !isAscending(b, CompareLinear)
+		patch, err = diffMerge(data, a, b, CompareLinear, LitLinear, context)
+	case LitEuler:
+		patch, err = diffMerge(data, a, b, CompareEuler, LitEuler, false)
+	case LitMultix:
+		patch, err = diffMerge(data, a, b, CompareMultix, LitMultix, false)
+	default:
+		err = ErrBadRecord
+	}
+	return
+}
+
+// Tuples are positional, so unchanged elements become () placeholders
+// unless those are trailing. The key of a map entry is always cited.
+func diffTuple(data, a, b []byte, keyed bool) (patch []byte, err error) {
+	patch = data
+	trim := len(patch)
+	ai := NewIter(a)
+	bi := NewIter(b)
+	for n := 0; err == nil; n++ {
+		ao := ai.Read()
+		bo := bi.Read()
+		if !ao && !bo {
+			break
+		}
+		l := len(patch)
+		if !bo {
+			patch = appendTombstone(patch, &ai, LitTuple)
+		} else if !ao {
+			patch = append(patch, bi.Record()...)
+		} else {
+			patch, err = diffElement(patch, &ai, &bi, LitTuple)
+		}
+		if len(patch) == l && keyed && n == 0 && bo {
+			patch = append(patch, bi.Record()...)
+		}
+		if len(patch) == l {
+			patch = append(patch, RDXEmptyTuple...)
+		} else {
+			trim = len(patch)
+		}
+	}
+	if err == nil {
+		err = firstError(&ai, &bi)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return patch[:trim], nil
+}
+
+// diffMerge walks two containers the way HeapMerge would. In the context
+// mode, unchanged elements of B and dead elements of A are cited up to the
+// last change; that is necessary for DISCONT trains to attach correctly,
+// as otherwise a tombstone may land as a new element next to the live one
+// it was meant to override.
+func diffMerge(data, a, b []byte, z Compare, plit byte, context bool) (patch []byte, err error) {
+	patch = data
+	trim := len(patch)
+	ai := NewIter(a)
+	bi := NewIter(b)
+	ao := ai.Read()
+	bo := bi.Read()
+	for (ao || bo) && err == nil {
+		l := len(patch)
+		c := Eq
+		if !bo {
+			c = Less
+		} else if !ao {
+			c = Grtr
+		} else {
+			c = z(&ai, &bi)
+		}
+		if c < Eq {
+			if context && !ai.IsLive() {
+				patch = append(patch, ai.Record()...)
+				l = len(patch)
+			} else {
+				patch = appendTombstone(patch, &ai, plit)
+			}
+			ao = ai.Read()
+		} else if c > Eq {
+			patch = append(patch, bi.Record()...)
+			bo = bi.Read()
+		} else {
+			patch, err = diffElement(patch, &ai, &bi, plit)
+			if context && len(patch) == l {
+				patch = append(patch, bi.Record()...)
+				l = len(patch)
+			}
+			ao = ai.Read()
+			bo = bi.Read()
+		}
+		if len(patch) != l || !context {
+			trim = len(patch)
+		}
+	}
+	if err == nil {
+		err = firstError(&ai, &bi)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return patch[:trim], nil
+}
+
+func isAscending(data []byte, z Compare) bool {
+	it := NewIter(data)
+	if !it.Read() {
+		return true
+	}
+	prev := it
+	for it.Read() {
+		if z(&prev, &it) >= Eq {
+			return false
+		}
+		prev = it
+	}
+	return true
+}
+
+func firstError(its ...*Iter) error {
+	for _, it := range its {
+		if it.HasFailed() {
+			return it.Error()
+		}
+	}
+	return nil
+}
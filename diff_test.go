@@ -0,0 +1,86 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	// B is a successor of A: changed elements have stamps that win,
+	// removed ones are tombstones, so A + D is exactly B, stamps included
+	cases := [][2]string{
+		{"1 2 3", "1 2@bob-1 3"},
+		{"(1 2 3)", "(1 5@bob-2 3@bob-1)"},
+		{"{1 2 3}", "{1 2@bob-1 3 4@bob-2}"},
+		{"{a:1, b:2}", "{a:1, b:3@bob-2, c:4@bob-2}"},
+		{"{a:1, b:{x:1}}", "{a:1, b:{x:2@bob-2, y:3@bob-2}}"},
+		{"{@alice-10 1 2}", "{@alice-10 1@bob-1 2 5@bob-2}"},
+		{"[a@10 b@30 c@50]", "[a@10 b@31 e@40 c@50 d@70]"},
+		{"<1@a-1, 2@b-2>", "<3@a-3, 2@b-2>"},
+		{"\"one\"@bob-5", "\"two\"@bob-6"},
+	}
+	for _, c := range cases {
+		a, err := ParseNormalizeJDR([]byte(c[0]))
+		assert.Nil(t, err)
+		b, err := ParseNormalizeJDR([]byte(c[1]))
+		assert.Nil(t, err)
+		patch, err := Diff(a, b)
+		assert.Nil(t, err, c[0]+" -> "+c[1])
+		merged, err := Merge(nil, [][]byte{a, patch})
+		assert.Nil(t, err)
+		assert.Equal(t, string(RenderJDR(b, 0)), string(RenderJDR(merged, 0)),
+			c[0]+" -> "+c[1]+" patch "+string(RenderJDR(patch, 0)))
+		same, err := Diff(a, a)
+		assert.Nil(t, err)
+		assert.Empty(t, same)
+	}
+}
+
+// The exception: if B does not outrank A, e.g. B lacks the tombstones or
+// its stamps are older, Diff mints stamps that win (see outrank). Then
+// A + D equals B once flattened, while its stamps dominate those of B.
+func TestDiffOutrank(t *testing.T) {
+	cases := [][2]string{
+		{"1 2 3", "1 3"},
+		{"1:2:3", "4"},
+		{"(1 2 3)", "(1 5)"},
+		{"{1 2 3}", "{1 3 4}"},
+		{"{1:2}", "{1}"},
+		{"{1}", "{1:2}"},
+		{"{a:1, b:2}", "{a:1, b:3, c:4}"},
+		{"{a:2, b:2}", "{a:1, b:2}"},
+		{"{a:1, b:{x:1}}", "{a:1, b:{x:2, y:3}}"},
+		{"{@alice-10 1 2}", "{@alice-10 2 5}"},
+		{"[1 2 3 4]", "[1 3]"},
+		{"[1 2 3]", "[1 2 3 4 5]"},
+		{"[(@1) b]", "[]"},
+		{"[{@1} b]", "[]"},
+		{"[a (@1) b]", "[a]"},
+		{"[(@1) b c]", "[c]"},
+		{"[a@10 b@30 c@50]", "[a@10 e@40 c@50 d@70]"},
+		{"[a@10 b@30 e@40 c@50 d@70]",
+			"[a@10 aa@bob-20 ab@10 ac@210 b@30 e@40 ad@410 c@50 ae@610 d@70]"},
+		{"<1@a-1, 2@b-2>", "<3@a-3, 2@b-2>"},
+		{"<1@a-4, 2@b-2>", "<0@a-2>"},
+		{"\"one\"@bob-5", "\"two\""},
+	}
+	for _, c := range cases {
+		a, err := ParseNormalizeJDR([]byte(c[0]))
+		assert.Nil(t, err)
+		b, err := ParseNormalizeJDR([]byte(c[1]))
+		assert.Nil(t, err)
+		patch, err := Diff(a, b)
+		assert.Nil(t, err, c[0]+" -> "+c[1])
+		merged, err := Merge(nil, [][]byte{a, patch})
+		assert.Nil(t, err)
+		want, _ := Flatten(nil, b)
+		got, _ := Flatten(nil, merged)
+		assert.Equal(t, string(RenderJDR(want, 0)), string(RenderJDR(got, 0)),
+			c[0]+" -> "+c[1]+" patch "+string(RenderJDR(patch, 0)))
+		again, err := Merge(nil, [][]byte{merged, b})
+		assert.Nil(t, err)
+		assert.Equal(t, string(RenderJDR(merged, 0)), string(RenderJDR(again, 0)),
+			c[0]+" -> "+c[1])
+	}
+}
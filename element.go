@@ -0,0 +1,174 @@
+package rdx
+
+import (
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Element is one RDX element, a view into the stream it was read from;
+// nothing is copied. Elements come from Elements, Children and Walk,
+// all of them iterators to use in `for range` loops:
+//
+//	for el, err := range rdx.Elements(data) {
+//		if err != nil { ... }
+//		for child, err := range el.Children() { ... }
+//	}
+type Element struct {
+	it Iter
+}
+
+// Path is the positions of an element and its parents, top-level first;
+// tombstones count.
+type Path []int
+
+func (p Path) String() string {
+	parts := make([]string, len(p))
+	for i, n := range p {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// Elements iterates over the top-level elements of a stream; a malformed
+// record ends the iteration with an error.
+func Elements(data Stream) iter.Seq2[Element, error] {
+	return func(yield func(Element, error) bool) {
+		it := NewIter(data)
+		for it.Read() {
+			if !yield(Element{it}, nil) {
+				return
+			}
+		}
+		if it.HasFailed() {
+			yield(Element{it}, it.Error())
+		}
+	}
+}
+
+// Children iterates over the elements of a PLEX container,
+// tombstones included; ErrNotPLEX for a FIRST element.
+func (e Element) Children() iter.Seq2[Element, error] {
+	if !IsPLEX(e.Lit()) {
+		return func(yield func(Element, error) bool) {
+			yield(Element{}, ErrNotPLEX)
+		}
+	}
+	return Elements(e.it.Value())
+}
+
+// Walk goes over all the elements depth first, parents before children.
+// The path is reused, copy it to keep it. A malformed record ends the
+// walk; its element has a non-nil Err().
+func Walk(data Stream) iter.Seq2[Path, Element] {
+	return func(yield func(Path, Element) bool) {
+		walk(NewIter(data), make(Path, 0, 8), yield)
+	}
+}
+
+func walk(it Iter, path Path, yield func(Path, Element) bool) bool {
+	n := 0
+	for ; it.Read(); n++ {
+		here := append(path, n)
+		if !yield(here, Element{it}) {
+			return false
+		}
+		if IsPLEX(it.Lit()) && !walk(it.Inner(), here, yield) {
+			return false
+		}
+	}
+	if it.HasFailed() {
+		yield(append(path, n), Element{it})
+		return false
+	}
+	return true
+}
+
+func (e Element) Lit() byte {
+	return e.it.Lit()
+}
+
+func (e Element) ID() ID {
+	return e.it.ID()
+}
+
+func (e Element) IsLive() bool {
+	return e.it.IsLive()
+}
+
+// Value is the raw value of the element, the body for a PLEX one.
+func (e Element) Value() []byte {
+	return e.it.Value()
+}
+
+func (e Element) Record() Stream {
+	return e.it.Record()
+}
+
+// Iter returns an iterator positioned on the element.
+func (e Element) Iter() Iter {
+	return e.it
+}
+
+// Err is the parse error of a malformed element, see Walk.
+func (e Element) Err() error {
+	return e.it.Error()
+}
+
+func (e Element) is(lit byte) error {
+	if e.it.HasFailed() {
+		return e.it.Error()
+	}
+	if e.Lit() != lit {
+		return ErrWrongRDXRecordType
+	}
+	return nil
+}
+
+// Int returns the value of an Integer; ErrWrongRDXRecordType otherwise.
+func (e Element) Int() (int64, error) {
+	if err := e.is(LitInteger); err != nil {
+		return 0, err
+	}
+	return int64(e.it.Integer()), nil
+}
+
+// Float returns the value of a Float; ErrWrongRDXRecordType otherwise.
+func (e Element) Float() (float64, error) {
+	if err := e.is(LitFloat); err != nil {
+		return 0, err
+	}
+	return float64(e.it.Float()), nil
+}
+
+// Ref returns the value of a Reference; ErrWrongRDXRecordType otherwise.
+func (e Element) Ref() (ID, error) {
+	if err := e.is(LitReference); err != nil {
+		return ID0, err
+	}
+	return e.it.Reference(), nil
+}
+
+// Str returns the value of a String; ErrWrongRDXRecordType otherwise.
+func (e Element) Str() (string, error) {
+	if err := e.is(LitString); err != nil {
+		return "", err
+	}
+	return string(e.it.Value()), nil
+}
+
+// Term returns the value of a Term; ErrWrongRDXRecordType otherwise.
+func (e Element) Term() (string, error) {
+	if err := e.is(LitTerm); err != nil {
+		return "", err
+	}
+	return string(e.it.Value()), nil
+}
+
+// Bytes returns the value of a String or a Term with no copying.
+func (e Element) Bytes() ([]byte, error) {
+	if err := e.is(LitString); err != nil && e.is(LitTerm) != nil {
+		return nil, err
+	}
+	return e.it.Value(), nil
+}
@@ -0,0 +1,88 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElements(t *testing.T) {
+	doc, err := ParseNormalizeJDR([]byte(`{name:"alice", age:42, tags:[x y]} 1.5 alice-4`))
+	assert.Nil(t, err)
+	var lits []byte
+	for el, err := range Elements(doc) {
+		assert.Nil(t, err)
+		lits = append(lits, el.Lit())
+	}
+	assert.Equal(t, []byte{LitEuler, LitFloat, LitReference}, lits)
+
+	n := 0
+	for el, err := range Elements(doc) {
+		assert.Nil(t, err)
+		switch n {
+		case 0:
+			var keys []string
+			for entry, err := range el.Children() {
+				assert.Nil(t, err)
+				for kv := range entry.Children() {
+					key, err := kv.Term()
+					assert.Nil(t, err)
+					keys = append(keys, key)
+					break
+				}
+			}
+			assert.Equal(t, []string{"age", "name", "tags"}, keys)
+			_, err = el.Int()
+			assert.ErrorIs(t, err, ErrWrongRDXRecordType)
+		case 1:
+			f, err := el.Float()
+			assert.Nil(t, err)
+			assert.Equal(t, 1.5, f)
+			for _, err := range el.Children() {
+				assert.ErrorIs(t, err, ErrNotPLEX)
+			}
+		case 2:
+			ref, err := el.Ref()
+			assert.Nil(t, err)
+			assert.Equal(t, "alice-4", ref.String())
+			_, err = el.Str()
+			assert.ErrorIs(t, err, ErrWrongRDXRecordType)
+		}
+		n++
+	}
+	assert.Equal(t, 3, n)
+
+	for el, err := range Elements(Stream{'i', 9, 0}) {
+		assert.Equal(t, ErrIncomplete, err)
+		assert.Equal(t, ErrIncomplete, el.Err())
+	}
+}
+
+func TestWalk(t *testing.T) {
+	doc := jdrOf(t, `(1 [2 "three"]) {4}`)
+	var paths []string
+	var vals []string
+	for path, el := range Walk(doc) {
+		paths = append(paths, path.String())
+		it := el.Iter()
+		vals = append(vals, it.String())
+	}
+	assert.Equal(t, []string{"0", "0.0", "0.1", "0.1.0", "0.1.1", "1", "1.0"}, paths)
+	assert.Equal(t, []string{"()", "1", "[]", "2", "three", "{}", "4"}, vals)
+
+	paths = paths[:0]
+	for path := range Walk(doc) {
+		if len(path) > 1 {
+			break
+		}
+		paths = append(paths, path.String())
+	}
+	assert.Equal(t, []string{"0"}, paths)
+
+	bad := append(jdrOf(t, "1 2"), 'i', 9, 0)
+	var last Element
+	for _, el := range Walk(bad) {
+		last = el
+	}
+	assert.Equal(t, ErrIncomplete, last.Err())
+}
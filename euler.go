@@ -0,0 +1,154 @@
+package rdx
+
+import "bytes"
+
+// Map and set editing, see y.E.md. An Eulerian map is a set of
+// (key value) tuples ordered by the key; a set element is its own key.
+// Every function here returns a minimal patch: an Euler element with
+// the ID of the edited one, holding the changed entry. An overwrite gets
+// a fresh clock stamp that beats the old entry in CompareLWW; a removal
+// is the old entry with an odd revision, see ID.Removed.
+
+// mapEntry finds the entry for the key, live or not
+func mapEntry(m, key Stream) (id ID, entry Iter, err error) {
+	if len(m) == 0 {
+		return
+	}
+	it := NewIter(m)
+	if !it.Read() || it.Lit() != LitEuler {
+		return ID0, Iter{}, ErrWrongRDXRecordType
+	}
+	k := NewIter(key)
+	if !k.Read() {
+		return ID0, Iter{}, ErrBadRecord
+	}
+	in := it.Inner()
+	for in.Read() {
+		if CompareEuler(&in, &k) == Eq {
+			return it.ID(), in, nil
+		}
+	}
+	if in.HasFailed() {
+		return ID0, Iter{}, in.Error()
+	}
+	return it.ID(), Iter{}, nil
+}
+
+// stampFor returns a clock stamp that beats the old entry, if any
+func stampFor(entry *Iter, clock *Clock) (ID, error) {
+	if entry.HasData() {
+		clock.See(entry.ID())
+	}
+	stamp := clock.Next()
+	if stamp == ID0 {
+		return ID0, clock.Err()
+	}
+	return stamp, nil
+}
+
+// MapGet returns the value for the key; ErrRecordNotFound if there
+// is none or the entry is deleted.
+func MapGet(m, key Stream) (value Stream, err error) {
+	_, entry, err := mapEntry(m, key)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.HasData() || !entry.IsLive() || entry.Lit() != LitTuple {
+		return nil, ErrRecordNotFound
+	}
+	in := entry.Inner()
+	if !in.Read() || !in.Read() {
+		return nil, ErrRecordNotFound
+	}
+	return in.Record(), nil
+}
+
+// MapSet returns a patch setting the value for the key; nil if the
+// map already has that. The value replaces the old one entirely, see
+// MapUpdate for editing a nested container.
+func MapSet(m, key, value Stream, clock *Clock) (patch Stream, err error) {
+	id, entry, err := mapEntry(m, key)
+	if err != nil {
+		return nil, err
+	}
+	if old, err := MapGet(m, key); err == nil && bytes.Equal(old, value) {
+		return nil, nil
+	}
+	stamp, err := stampFor(&entry, clock)
+	if err != nil {
+		return nil, err
+	}
+	body := append(append(Stream{}, key...), value...)
+	patch = WriteRDX(nil, LitTuple, stamp, body)
+	return WriteRDX(nil, LitEuler, id, patch), nil
+}
+
+// MapUpdate wraps a patch to the container at the key (e.g. a nested
+// map edited with MapSet) into a patch to the map. As the stamps are
+// kept, the patch merges into the nested container instead of
+// replacing it.
+func MapUpdate(m, key, nested Stream) (patch Stream, err error) {
+	id, entry, err := mapEntry(m, key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := MapGet(m, key)
+	if err != nil {
+		return nil, err
+	}
+	a, b := NewIter(value), NewIter(nested)
+	if !a.Read() || !b.Read() || !IsPLEX(a.Lit()) || !IsSame(&a, &b) {
+		return nil, ErrWrongRDXRecordType
+	}
+	body := append(append(Stream{}, key...), nested...)
+	patch = WriteRDX(nil, LitTuple, entry.ID(), body)
+	return WriteRDX(nil, LitEuler, id, patch), nil
+}
+
+// MapDelete returns a patch removing the key; nil if there is no such key.
+func MapDelete(m, key Stream) (patch Stream, err error) {
+	id, entry, err := mapEntry(m, key)
+	if err != nil || !entry.HasData() || !entry.IsLive() {
+		return nil, err
+	}
+	patch = appendTombstone(nil, &entry, LitEuler)
+	return WriteRDX(nil, LitEuler, id, patch), nil
+}
+
+// SetHas reports whether the set has a live element equal to elem.
+func SetHas(s, elem Stream) bool {
+	_, entry, err := mapEntry(s, elem)
+	if err != nil || !entry.HasData() || !entry.IsLive() {
+		return false
+	}
+	e := NewIter(elem)
+	e.Read()
+	return entry.Lit() == e.Lit() && bytes.Equal(entry.Value(), e.Value())
+}
+
+// SetAdd returns a patch adding the element; nil if the set has it.
+func SetAdd(s, elem Stream, clock *Clock) (patch Stream, err error) {
+	id, entry, err := mapEntry(s, elem)
+	if err != nil {
+		return nil, err
+	}
+	if SetHas(s, elem) {
+		return nil, nil
+	}
+	lit, _, val, _, err := ReadRDX(elem)
+	if err != nil {
+		return nil, err
+	}
+	stamp, err := stampFor(&entry, clock)
+	if err != nil {
+		return nil, err
+	}
+	patch = WriteRDX(nil, lit, stamp, val)
+	return WriteRDX(nil, LitEuler, id, patch), nil
+}
+
+// SetRemove returns a patch removing the element; nil if the set
+// has no such element.
+func SetRemove(s, elem Stream) (patch Stream, err error) {
+	return MapDelete(s, elem)
+}
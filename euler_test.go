@@ -0,0 +1,74 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapEdits(t *testing.T) {
+	clock := NewLamportClock(1)
+	doc, err := ParseNormalizeJDR([]byte("{1:2, 3:{4,5}}"))
+	assert.Nil(t, err)
+	apply := func(patch Stream, err error, want string) {
+		assert.Nil(t, err)
+		doc, err = Merge(nil, [][]byte{doc, patch})
+		assert.Nil(t, err)
+		norm, err := ParseNormalizeJDR([]byte(want))
+		assert.Nil(t, err)
+		assert.Equal(t, flatJDR(t, norm), flatJDR(t, doc), string(RenderJDR(patch, StyleStamps)))
+	}
+	val, err := MapGet(doc, I0(1))
+	assert.Nil(t, err)
+	assert.Equal(t, I0(2), val)
+
+	patch, err := MapSet(doc, I0(1), S0("two"), clock)
+	apply(patch, err, `{1:"two",3:{4,5}}`)
+	patch, err = MapSet(doc, I0(1), S0("two"), clock)
+	assert.Nil(t, err)
+	assert.Nil(t, patch)
+	patch, err = MapSet(doc, I0(7), I0(8), clock)
+	apply(patch, err, `{1:"two",3:{4,5},7:8}`)
+	patch, err = MapDelete(doc, I0(1))
+	apply(patch, err, `{3:{4,5},7:8}`)
+	_, err = MapGet(doc, I0(1))
+	assert.Equal(t, ErrRecordNotFound, err)
+	patch, err = MapSet(doc, I0(1), I0(1), clock)
+	apply(patch, err, `{1:1,3:{4,5},7:8}`)
+
+	inner, err := MapGet(doc, I0(3))
+	assert.Nil(t, err)
+	nested, err := MapSet(inner, I0(4), I0(10), clock)
+	assert.Nil(t, err)
+	patch, err = MapUpdate(doc, I0(3), nested)
+	apply(patch, err, `{1:1,3:{4:10,5},7:8}`)
+	_, err = MapUpdate(doc, I0(7), nested)
+	assert.Equal(t, ErrWrongRDXRecordType, err)
+	patch, err = MapSet(doc, I0(3), I0(0), clock)
+	apply(patch, err, `{1:1,3:0,7:8}`)
+}
+
+func TestSetEdits(t *testing.T) {
+	alice, bob := NewLamportClock(1), NewLamportClock(2)
+	doc, err := ParseNormalizeJDR([]byte("{1 2 3}"))
+	assert.Nil(t, err)
+	assert.True(t, SetHas(doc, I0(2)))
+	del, err := SetRemove(doc, I0(2))
+	assert.Nil(t, err)
+	add, err := SetAdd(doc, S0("x"), alice)
+	assert.Nil(t, err)
+	doc, err = Merge(nil, [][]byte{doc, del, add})
+	assert.Nil(t, err)
+	assert.Equal(t, `{1 3 "x"}`, flatJDR(t, doc))
+	assert.False(t, SetHas(doc, I0(2)))
+	patch, err := SetRemove(doc, I0(2))
+	assert.Nil(t, err)
+	assert.Nil(t, patch)
+
+	readd, err := SetAdd(doc, I0(2), bob)
+	assert.Nil(t, err)
+	doc2, err := Merge(nil, [][]byte{doc, readd, del})
+	assert.Nil(t, err)
+	assert.Equal(t, `{1 2 3 "x"}`, flatJDR(t, doc2))
+	assert.True(t, SetHas(doc2, I0(2)))
+}
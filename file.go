@@ -0,0 +1,211 @@
+package rdx
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const (
+	fileReadSize  = 1 << 16
+	fileIndexStep = 1 << 12
+)
+
+// FileReader iterates the top-level records of a binary RDX file
+// through a buffered window, so files larger than RAM can be read,
+// seeked and merged same as an in-memory Iter. Seek assumes the
+// records are ordered by ID; it uses a sparse index of the record
+// offsets seen so far, so repeated seeks do not rescan the file.
+type FileReader struct {
+	file  *os.File
+	buf   []byte
+	start int64
+	off   int64 // file offset of buf[0]
+	pos   int   // offset of the current record in buf
+	it    Iter
+	eof   bool
+	err   error
+	index []fileMark
+}
+
+type fileMark struct {
+	off int64
+	id  ID
+}
+
+var _ ReadSeekCloser = (*FileReader)(nil)
+
+func OpenFileReader(path string) (*FileReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileReader(file), nil
+}
+
+// NewFileReader reads the file from its current position;
+// the reader takes the ownership of the file.
+func NewFileReader(file *os.File) *FileReader {
+	off, _ := file.Seek(0, io.SeekCurrent)
+	return &FileReader{
+		file:  file,
+		buf:   make([]byte, 0, fileReadSize),
+		start: off,
+		off:   off,
+	}
+}
+
+// recordLen returns the full length of the record at the start
+// of data, or 0 if the header is incomplete
+func recordLen(data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	lit := data[0]
+	if lit >= 'a' && lit <= 'z' {
+		if len(data) < 2 {
+			return 0, nil
+		}
+		return 2 + int(data[1]), nil
+	} else if lit >= 'A' && lit <= 'Z' {
+		if len(data) < 5 {
+			return 0, nil
+		}
+		l := binary.LittleEndian.Uint32(data[1:5])
+		if l >= 1<<30 {
+			return 0, ErrBadRecord
+		}
+		return 5 + int(l), nil
+	}
+	return 0, ErrBadRecord
+}
+
+// fill reads more data into the window, dropping the bytes before pos
+func (f *FileReader) fill() {
+	if f.pos > 0 {
+		n := copy(f.buf, f.buf[f.pos:])
+		f.buf = f.buf[:n]
+		f.off += int64(f.pos)
+		f.pos = 0
+	}
+	if cap(f.buf)-len(f.buf) < fileReadSize/2 {
+		buf := make([]byte, len(f.buf), cap(f.buf)*2)
+		copy(buf, f.buf)
+		f.buf = buf
+	}
+	n, err := f.file.Read(f.buf[len(f.buf):cap(f.buf)])
+	f.buf = f.buf[:len(f.buf)+n]
+	if err == io.EOF {
+		f.eof = true
+	} else if err != nil {
+		f.err = err
+	}
+}
+
+func (f *FileReader) Read() bool {
+	if f.err != nil {
+		return false
+	}
+	if f.it.HasData() {
+		f.pos += len(f.it.Record())
+		f.it = Iter{}
+	}
+	for {
+		n, err := recordLen(f.buf[f.pos:])
+		if err != nil {
+			f.err = err
+			return false
+		}
+		if n > 0 && f.pos+n <= len(f.buf) {
+			f.it = NewIter(f.buf[f.pos : f.pos+n])
+			if !f.it.Read() {
+				f.err = f.it.Error()
+				return false
+			}
+			break
+		}
+		if f.eof {
+			if f.pos < len(f.buf) {
+				f.err = ErrIncomplete
+			}
+			return false
+		}
+		f.fill()
+		if f.err != nil {
+			return false
+		}
+	}
+	at := f.off + int64(f.pos)
+	if len(f.index) == 0 || at >= f.index[len(f.index)-1].off+fileIndexStep {
+		f.index = append(f.index, fileMark{off: at, id: f.it.ID()})
+	}
+	return true
+}
+
+func (f *FileReader) Record() Stream {
+	if !f.it.HasData() {
+		return nil
+	}
+	return f.it.Record()
+}
+
+func (f *FileReader) Parsed() (lit byte, id ID, value []byte) {
+	return f.it.Parsed()
+}
+
+func (f *FileReader) Error() error {
+	return f.err
+}
+
+// Offset returns the file offset of the current record.
+func (f *FileReader) Offset() int64 {
+	return f.off + int64(f.pos)
+}
+
+func (f *FileReader) rewind(off int64) bool {
+	if _, err := f.file.Seek(off, io.SeekStart); err != nil {
+		f.err = err
+		return false
+	}
+	f.buf = f.buf[:0]
+	f.off = off
+	f.pos = 0
+	f.it = Iter{}
+	f.eof = false
+	return true
+}
+
+// Seek moves to the first record with an equal-or-greater ID;
+// returns Eq or Grtr; if none found, returns Less.
+func (f *FileReader) Seek(id ID) int {
+	if f.err != nil {
+		return Less
+	}
+	if !f.it.HasData() || f.it.ID().Compare(id) > Eq {
+		i := len(f.index) - 1
+		for i >= 0 && f.index[i].id.Compare(id) >= Eq {
+			i--
+		}
+		start := f.start
+		if i >= 0 {
+			start = f.index[i].off
+		}
+		if !f.rewind(start) || !f.Read() {
+			return Less
+		}
+	}
+	z := f.it.ID().Compare(id)
+	for z < Eq && f.Read() {
+		z = f.it.ID().Compare(id)
+	}
+	if z < Eq {
+		return Less
+	}
+	return z
+}
+
+func (f *FileReader) Close() error {
+	f.it = Iter{}
+	f.buf = nil
+	return f.file.Close()
+}
@@ -0,0 +1,56 @@
+package rdx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "test.rdx")
+	assert.Nil(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+func TestFileReader(t *testing.T) {
+	var data []byte
+	const n = 10000
+	for i := 1; i <= n; i++ {
+		data = append(data, I(ID{Src: 1, Seq: uint64(i) << 7}, Integer(i))...)
+	}
+	big := strings.Repeat("x", fileReadSize*3)
+	data = append(data, S(ID{Src: 1, Seq: (n + 1) << 7}, big)...)
+
+	f, err := OpenFileReader(writeTestFile(t, data))
+	assert.Nil(t, err)
+	it := NewIter(data)
+	for it.Read() {
+		assert.True(t, f.Read())
+		assert.Equal(t, it.Record(), f.Record())
+	}
+	assert.False(t, f.Read())
+	assert.Nil(t, f.Error())
+
+	assert.Equal(t, Eq, f.Seek(ID{Src: 1, Seq: 5000 << 7}))
+	lit, _, val := f.Parsed()
+	assert.Equal(t, byte(LitInteger), lit)
+	assert.Equal(t, int64(5000), UnzipInt64(val))
+	assert.Equal(t, Grtr, f.Seek(ID{Src: 0, Seq: 100 << 7}))
+	assert.Equal(t, int64(100), UnzipInt64(f.it.Value()))
+	assert.Equal(t, Eq, f.Seek(ID{Src: 1, Seq: 9999 << 7}))
+	assert.True(t, f.Read())
+	assert.True(t, f.Read())
+	assert.Equal(t, big, string(f.it.Value()))
+	assert.Equal(t, Less, f.Seek(ID{Src: 1, Seq: (n + 2) << 7}))
+	assert.Nil(t, f.Close())
+
+	f, err = OpenFileReader(writeTestFile(t, data[:len(data)-1]))
+	assert.Nil(t, err)
+	for f.Read() {
+	}
+	assert.Equal(t, ErrIncomplete, f.Error())
+	assert.Nil(t, f.Close())
+}
@@ -0,0 +1,83 @@
+package rdx
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// Hash returns the structural hash of a document: the document is
+// normalized, then every element is hashed recursively. A FIRST element
+// hashes its type, stamp and value; a container hashes its type, stamp
+// and the hashes of its children. Equal documents hash equally no matter
+// the way those were produced or serialized.
+func Hash(rdx Stream) (sum Sha256, err error) {
+	norm, err := Normalize(rdx)
+	if err != nil {
+		return
+	}
+	return hashNormal(norm)
+}
+
+// HashView is Hash of the user view of a document, i.e. the Flatten
+// output: no tombstones, no stamps. Replicas that differ only in the
+// metadata hash the same.
+func HashView(rdx Stream) (sum Sha256, err error) {
+	norm, err := Normalize(rdx)
+	if err != nil {
+		return
+	}
+	flat, err := Flatten(nil, norm)
+	if err != nil {
+		return
+	}
+	return hashNormal(flat)
+}
+
+func hashNormal(norm Stream) (sum Sha256, err error) {
+	h := sha256.New()
+	h.Write([]byte{'S'})
+	it := NewIter(norm)
+	var child Sha256
+	for it.Read() && err == nil {
+		child, err = hashElement(&it)
+		h.Write(child[:])
+	}
+	if err == nil && it.HasFailed() {
+		err = it.Error()
+	}
+	h.Sum(sum[:0])
+	return
+}
+
+func hashHeader(h hash.Hash, it *Iter) {
+	zip := ZipID(it.ID())
+	var hdr [2 + 16 + binary.MaxVarintLen64]byte
+	hdr[0] = it.Lit()
+	hdr[1] = byte(len(zip))
+	n := 2 + copy(hdr[2:], zip)
+	if !IsPLEX(it.Lit()) {
+		n += binary.PutUvarint(hdr[n:], uint64(len(it.Value())))
+	}
+	h.Write(hdr[:n])
+}
+
+func hashElement(it *Iter) (sum Sha256, err error) {
+	h := sha256.New()
+	hashHeader(h, it)
+	if !IsPLEX(it.Lit()) {
+		h.Write(it.Value())
+	} else {
+		in := it.Inner()
+		var child Sha256
+		for in.Read() && err == nil {
+			child, err = hashElement(&in)
+			h.Write(child[:])
+		}
+		if err == nil && in.HasFailed() {
+			err = in.Error()
+		}
+	}
+	h.Sum(sum[:0])
+	return
+}
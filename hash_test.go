@@ -0,0 +1,51 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	hashOf := func(jdr string) Sha256 {
+		rdx, err := ParseJDR([]byte(jdr))
+		assert.Nil(t, err)
+		sum, err := Hash(rdx)
+		assert.Nil(t, err)
+		return sum
+	}
+	viewOf := func(jdr string) Sha256 {
+		rdx, err := ParseJDR([]byte(jdr))
+		assert.Nil(t, err)
+		sum, err := HashView(rdx)
+		assert.Nil(t, err)
+		return sum
+	}
+	same := [][2]string{
+		{"{a:1, b:2}", "{ b:2,\n a:1 }"},
+		{"{1 2 3}", "{3 2 1 2}"},
+		{"(1 2 3)", "1:2:3"},
+		{"<1@b-2, 2@a-2>", "<2@a-2 1@b-2>"},
+	}
+	for _, c := range same {
+		assert.Equal(t, hashOf(c[0]), hashOf(c[1]), c[0])
+	}
+	differ := [][2]string{
+		{"1", "2"},
+		{"1", "1@a-2"},
+		{"\"a\"", "a"},
+		{"[1 2]", "{1 2}"},
+		{"(1 2)", "(1) 2"},
+		{"[1 [2]]", "[[1] 2]"},
+	}
+	for _, c := range differ {
+		assert.NotEqual(t, hashOf(c[0]), hashOf(c[1]), c[0])
+	}
+	assert.Equal(t, viewOf("{a:1@bob-2, b:2@alice-4, c@alice-3}"), viewOf("{a:1, b:2}"))
+	assert.NotEqual(t, hashOf("{a:1@bob-2, b:2@alice-4}"), hashOf("{a:1, b:2}"))
+	assert.Equal(t, viewOf("[1@b-2 2@a-4]"), viewOf("[1 2]"))
+
+	empty, err := Hash(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, Sha256Of([]byte{'S'}), empty)
+}
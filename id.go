@@ -241,7 +241,7 @@ func Timestamp() (t uint64) {
 	t = t | (uint64(now.Hour()) << (5 * 6))
 	t = t | (uint64(now.Minute()) << (4 * 6))
 	t = t | (uint64(now.Second()) << (3 * 6))
-	t = t | (uint64(now.Nanosecond() >> 2))
+	t = t | (uint64(now.Nanosecond()) << (3 * 6) / 1e9)
 	return
 }
 
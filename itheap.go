@@ -336,23 +336,27 @@ func (ih Heap) Up(a int, z Compare) {
 }
 
 func (ih Heap) EqUp(z Compare) (eqs int) {
+	eqs, _ = ih.eqUp(z, make([]int, 0, MaxInputs))
+	return
+}
+
+// eqUp is EqUp with a reusable queue
+func (ih Heap) eqUp(z Compare, q []int) (eqs int, queue []int) {
 	if len(ih) < 2 {
-		return len(ih)
+		return len(ih), q
 	}
-	q := make([]int, 0, MaxInputs)
-	q = append(q, 1, 2)
+	q = append(q[:0], 1, 2)
 	eqs = 1
-	for len(q) > 0 && q[0] < len(ih) {
-		n := q[0]
+	for h := 0; h < len(q) && q[h] < len(ih); h++ {
+		n := q[h]
 		if Eq == z(&ih[0], &ih[n]) {
 			j1 := 2*n + 1
 			q = append(q, j1, j1+1)
 			ih[eqs], ih[n] = ih[n], ih[eqs]
 			eqs++
 		}
-		q = q[1:]
 	}
-	return
+	return eqs, q
 }
 
 func (heap *Heap) Remove(i int, z Compare) {
@@ -410,16 +414,23 @@ func (heap *Heap) MergeNext(data []byte, Z Compare) ([]byte, error) {
 		data, err = MergeSameSpotElements(data, eqs)
 	}
 	if err == nil {
-		err = heap.NextK(eqlen, Z) // FIXME signature
-		if eqlen > 1 {
-			for i := eqlen; i < len(*heap); i++ { // FIXME bad
-				heap.Up(i, Z)
-			}
-		}
+		err = heap.nextEqs(eqlen, Z)
 	}
 	return data, err
 }
 
+// nextEqs moves on the first k inputs, as put there by EqUp
+func (heap *Heap) nextEqs(k int, z Compare) error {
+	err := heap.NextK(k, z) // FIXME signature
+	// EqUp shuffled the heap, so rebuild it
+	if k > 1 {
+		for i := len(*heap)/2 - 1; i >= 0; i-- {
+			(*heap).Down(i, z)
+		}
+	}
+	return err
+}
+
 func (heap *Heap) IntersectNext(Z Compare) (ret Iter, err error) {
 	l := len(*heap)
 	if l == 0 {
@@ -440,12 +451,9 @@ func (heap *Heap) IntersectNext(Z Compare) (ret Iter, err error) {
 }
 
 func HeapMerge(data []byte, inputs [][]byte, Z Compare) (res []byte, err error) {
-	var heap Heap
-	heap, err = Heapize(inputs, Z)
-	res = data
-	for len(heap) > 0 && err == nil {
-		res, err = heap.MergeNext(res, Z)
-	}
+	m := getMergeContext()
+	res, err = m.HeapMerge(data, inputs, Z)
+	putMergeContext(m)
 	return
 }
 
@@ -462,6 +470,9 @@ type Heap2 struct {
 	oldlen int
 	z      LessFn
 	y      MergeFn
+	err    error
+	eqs    []int
+	merge  []Iter
 }
 
 func (heap *Heap2) Len() int {
@@ -478,11 +489,10 @@ func (heap *Heap2) AddIter(it Iter) {
 	HeapUp(heap)
 }
 func (heap *Heap2) Read() bool {
-	if len(heap.inputs) == 0 {
+	if len(heap.inputs) == 0 || heap.err != nil {
 		return false
 	}
-	eqs := make([]int, 0, MaxInputs)
-	eqs = append(eqs, 0)
+	eqs := append(heap.eqs[:0], 0)
 	for i := 0; i < len(eqs); i++ {
 		k := eqs[i]
 		kl := k*2 + 1
@@ -496,23 +506,28 @@ func (heap *Heap2) Read() bool {
 			}
 		}
 	}
+	heap.eqs = eqs
 	var merge []Iter
 	if eqs[len(eqs)-1] == len(eqs)-1 {
 		merge = heap.inputs[:len(eqs)]
 	} else {
-		merge = make([]Iter, 0, len(eqs))
+		merge = heap.merge[:0]
 		for i := 0; i < len(eqs); i++ {
 			merge = append(merge, heap.inputs[eqs[i]])
 		}
+		heap.merge = merge
 	}
 	heap.oldlen = len(heap.out)
 	heap.out = heap.y(merge, heap.out)
 	for i := len(eqs) - 1; i >= 0; i-- {
-		heap.inputs[eqs[i]].Read()
+		in := &heap.inputs[eqs[i]]
+		if !in.Read() && in.HasFailed() && heap.err == nil {
+			heap.err = in.Error()
+		}
 	}
 	for i := len(eqs) - 1; i >= 0; i-- {
 		k := eqs[i]
-		if !heap.inputs[k].HasData() {
+		if !heap.inputs[k].HasData() || heap.inputs[k].HasFailed() {
 			heap.inputs[k] = heap.inputs[len(heap.inputs)-1]
 			heap.inputs = heap.inputs[:len(heap.inputs)-1]
 		}
@@ -528,16 +543,31 @@ func (heap *Heap2) Parsed() (lit byte, id ID, value []byte) {
 	it.Read()
 	return it.Parsed()
 }
+
+// Error returns the first error of any input; the merge stops there.
 func (heap *Heap2) Error() error {
-	return nil
+	return heap.err
 }
+
+// ReadAll merges all the remaining records; Record() returns all of
+// them then.
 func (heap *Heap2) ReadAll() (err error) {
-	return
+	from := len(heap.out)
+	for heap.Read() {
+	}
+	heap.oldlen = from
+	return heap.err
 }
 func MakeHeap2(inputs []Iter, y MergeFn, z LessFn) (heap Heap2) {
 	heap.y = y
 	heap.z = z
 	for _, i := range inputs {
+		if i.IsAtStart() && !i.Read() {
+			if i.HasFailed() && heap.err == nil {
+				heap.err = i.Error()
+			}
+			continue
+		}
 		heap.AddIter(i)
 	}
 	return
@@ -564,7 +594,7 @@ func (o *ObjectReader) Read() bool {
 			continue
 		}
 		o.Value = NewIter(o.it.Value())
-		if !o.Value.Read() || o.Value.Lit() != LitTerm {
+		if !o.Value.Read() || (o.Value.Lit() != LitTerm && o.Value.Lit() != LitString) {
 			continue
 		}
 		o.Key = o.Value.String()
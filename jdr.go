@@ -16,6 +16,8 @@ type JDRstate struct {
 
 	line int
 	col  int
+
+	bad []byte // the token a callback failed on, see JDRSyntaxError
 }
 
 var RDXEmptyTuple []byte = []byte{'p', 1, 0}
@@ -138,6 +140,7 @@ func JDRonStamp(tok []byte, state *JDRstate) error {
 	idstr := tok[1:]
 	id, err := NewID(idstr)
 	if err != nil {
+		state.bad = tok
 		return err
 	}
 	zip := ZipID(id)
@@ -174,6 +177,7 @@ func JDRonFIRST(tok []byte, state *JDRstate) (err error) {
 	case LitReference:
 		id, e := NewID(state.val)
 		if e != nil {
+			state.bad = state.val
 			return e
 		}
 		state.rdx = append(state.rdx, ZipID(id)...)
@@ -266,6 +270,7 @@ func JDRonClosePLEX(tok []byte, state *JDRstate, lit byte) (err error) {
 		err = appendRDXEmptyTuple(state)
 	}
 	if !IsPLEX(lit) || lit != state.Line().Lit {
+		state.bad = tok
 		err = ErrBadJDRNesting
 	}
 	if err != nil {
@@ -757,7 +762,9 @@ func WriteAllJDR(jdr, rdx []byte, style Style) (jdr2 []byte, err error) {
 	return jdr2, err
 }
 
-// ParseJDR parses with no normalization, hence return type is []byte not Stream
+// ParseJDR parses with no normalization, hence return type is []byte not Stream.
+// Errors are *JDRSyntaxError, matching ErrBadJDRSyntax, ErrBadJDRNesting
+// and the like with errors.Is.
 func ParseJDR(jdr []byte) (rdx []byte, err error) {
 	state := JDRstate{
 		jdr:   jdr,
@@ -766,8 +773,12 @@ func ParseJDR(jdr []byte) (rdx []byte, err error) {
 	state.stack = append(state.stack, Mark{Lit: ' '})
 	err = JDRlexer(&state)
 	if err == nil && (len(state.stack) != 1 || state.stack[0].Lit != ' ') {
+		state.bad = jdr[len(jdr):] // unclosed at the end
 		err = ErrBadJDRNesting
 	}
+	if err != nil {
+		err = newJDRSyntaxError(jdr, &state, err)
+	}
 	rdx = state.rdx
 	return
 }
@@ -1,9 +1,12 @@
 package rdx
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestReadWriteJDR(t *testing.T) {
@@ -58,3 +61,46 @@ func TestTuples(t *testing.T) {
 		}
 	}
 }
+
+func TestJDRSyntaxError(t *testing.T) {
+	_, err := ParseJDR([]byte("{a:1\n\tb:{x:[1 2}}"))
+	assert.ErrorIs(t, err, ErrBadJDRNesting)
+	var se *JDRSyntaxError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 2, se.Line)
+	assert.Equal(t, 11, se.Col)
+	assert.Equal(t, 15, se.Offset)
+	assert.Equal(t, "}", se.Token)
+	assert.Equal(t, []string{"]"}, se.Expected)
+	assert.Equal(t, "{{[", se.Open)
+	assert.Equal(t, "\tb:{x:[1 2}}\n\t         ^", se.Excerpt)
+	assert.Equal(t, `2:11: bad JDR syntax (nesting) near "}"; expected ]; open {{[`,
+		err.Error())
+
+	_, err = ParseJDR([]byte("[1,\n 2 ? 3]"))
+	assert.ErrorIs(t, err, ErrBadJDRSyntax)
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 2, se.Line)
+	assert.Equal(t, 4, se.Col)
+	assert.Equal(t, "?", se.Token)
+	assert.Contains(t, se.Expected, "digit")
+	assert.Contains(t, se.Expected, "]")
+	assert.NotContains(t, se.Expected, "?")
+	assert.Equal(t, "[", se.Open)
+
+	_, err = ParseJDR([]byte("(1 2"))
+	assert.ErrorIs(t, err, ErrBadJDRNesting)
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 5, se.Col)
+	assert.Equal(t, "", se.Token)
+	assert.Equal(t, []string{")"}, se.Expected)
+
+	_, err = ParseJDR([]byte("1 2 )"))
+	assert.ErrorIs(t, err, ErrBadJDRNesting)
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, ")", se.Token)
+	assert.Equal(t, "", se.Open)
+
+	_, err = ParseJDR([]byte(`"abc`))
+	assert.ErrorIs(t, err, ErrIncomplete)
+}
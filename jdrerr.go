@@ -0,0 +1,203 @@
+package rdx
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// JDRSyntaxError is a JDR parsing error with its position in the text.
+// The underlying error is one of ErrBadJDRSyntax, ErrBadJDRNesting,
+// ErrIncomplete and the like, so errors.Is works as before.
+//
+//	2:9: bad JDR syntax near "@"; expected one of ...
+//	{a:1, b:@}
+//	        ^
+type JDRSyntaxError struct {
+	Line   int // 1-based
+	Col    int // 1-based, in bytes
+	Offset int
+	// Token is the offending token, empty at the end of the text
+	Token string
+	// Expected lists what the lexer could accept at the offset,
+	// like `}`, `digit` or `letter`; empty if unknown
+	Expected []string
+	// Open is the brackets left open at the offset, outermost first
+	Open string
+	// Excerpt is the line of the text with a caret under the offset
+	Excerpt string
+	Err     error
+}
+
+func (e *JDRSyntaxError) Error() string {
+	msg := strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Col) + ": " + e.Err.Error()
+	if e.Token != "" {
+		msg += " near " + strconv.Quote(e.Token)
+	} else {
+		msg += " at the end"
+	}
+	if len(e.Expected) == 1 {
+		msg += "; expected " + e.Expected[0]
+	} else if len(e.Expected) > 1 {
+		msg += "; expected one of " + strings.Join(e.Expected, " ")
+	}
+	if e.Open != "" {
+		msg += "; open " + e.Open
+	}
+	return msg
+}
+
+func (e *JDRSyntaxError) Unwrap() error {
+	return e.Err
+}
+
+var jdrBrackets = map[byte][2]byte{
+	LitTuple:  {'(', ')'},
+	LitLinear: {'[', ']'},
+	LitEuler:  {'{', '}'},
+	LitMultix: {'<', '>'},
+}
+
+func newJDRSyntaxError(jdr []byte, state *JDRstate, err error) *JDRSyntaxError {
+	e := &JDRSyntaxError{Err: err}
+	switch {
+	case state.bad != nil && cap(jdr) >= cap(state.bad):
+		e.Offset = cap(jdr) - cap(state.bad)
+	case err == ErrIncomplete:
+		e.Offset = len(jdr)
+	default:
+		e.Offset = len(jdr) - len(state.jdr)
+		if err != ErrBadJDRSyntax && e.Offset > 0 {
+			e.Offset-- // a callback fails past its token
+		}
+	}
+	e.Offset = min(max(e.Offset, 0), len(jdr))
+	e.Token = jdrToken(jdr[e.Offset:])
+	for _, mark := range state.stack {
+		if br, ok := jdrBrackets[mark.Lit]; ok {
+			e.Open += string(br[0])
+		}
+	}
+	switch err {
+	case ErrBadJDRSyntax, ErrIncomplete:
+		e.Expected = jdrExpected(jdrLexerState(jdr[:e.Offset]))
+	case ErrBadJDRNesting:
+		if n := len(state.stack); n > 0 {
+			if br, ok := jdrBrackets[state.stack[n-1].Lit]; ok {
+				e.Expected = []string{string(br[1])}
+			}
+		}
+	}
+	e.Line = 1 + bytes.Count(jdr[:e.Offset], []byte{'\n'})
+	from := bytes.LastIndexByte(jdr[:e.Offset], '\n') + 1
+	till := bytes.IndexByte(jdr[e.Offset:], '\n')
+	if till < 0 {
+		till = len(jdr)
+	} else {
+		till += e.Offset
+	}
+	e.Col = e.Offset - from + 1
+	line := jdr[from:till]
+	caret := bytes.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, jdr[from:e.Offset])
+	e.Excerpt = string(line) + "\n" + string(caret) + "^"
+	return e
+}
+
+// jdrToken cuts the token at the start of the text: a word or
+// a single punctuation character
+func jdrToken(text []byte) string {
+	const delims = " \t\r\n,;:()[]{}<>\""
+	if len(text) == 0 {
+		return ""
+	}
+	if strings.IndexByte(delims, text[0]) >= 0 {
+		return string(text[:1])
+	}
+	n := bytes.IndexAny(text, delims)
+	if n < 0 {
+		n = len(text)
+	}
+	return string(text[:n])
+}
+
+// jdrLexerState replays the lexer tables (no actions) to find the
+// state of the machine at the end of the text
+func jdrLexerState(text []byte) int {
+	cs := JDR_start
+	for p := 0; p < len(text) && cs != JDR_error; p++ {
+		cs = jdrLexerNext(cs, text[p])
+	}
+	return cs
+}
+
+func jdrLexerNext(cs int, c byte) int {
+	return int(_JDR_trans_targs[jdrLexerTrans(cs, c)])
+}
+
+// jdrLexerTrans finds the transition of the machine for the byte
+func jdrLexerTrans(cs int, c byte) int {
+	keys := int(_JDR_key_offsets[cs])
+	trans := int(_JDR_index_offsets[cs])
+	n := int(_JDR_single_lengths[cs])
+	for i := 0; i < n; i++ {
+		if _JDR_trans_keys[keys+i] == c {
+			return trans + i
+		}
+	}
+	keys += n
+	trans += n
+	n = int(_JDR_range_lengths[cs])
+	for i := 0; i < n; i++ {
+		if c >= _JDR_trans_keys[keys+2*i] && c <= _JDR_trans_keys[keys+2*i+1] {
+			return trans + i
+		}
+	}
+	return trans + n
+}
+
+// jdrExpected lists the bytes the lexer state accepts, by classes
+func jdrExpected(cs int) (expected []string) {
+	if cs == JDR_error {
+		return nil
+	}
+	var ok [256]bool
+	for c := 0; c < 256; c++ {
+		ok[c] = jdrLexerNext(cs, byte(c)) != JDR_error
+	}
+	all := func(from, till byte) bool {
+		for c := int(from); c <= int(till); c++ {
+			if !ok[c] {
+				return false
+			}
+		}
+		return true
+	}
+	drop := func(from, till byte) {
+		for c := int(from); c <= int(till); c++ {
+			ok[c] = false
+		}
+	}
+	if all('0', '9') {
+		expected = append(expected, "digit")
+		drop('0', '9')
+	}
+	if all('a', 'z') && all('A', 'Z') {
+		expected = append(expected, "letter")
+		drop('a', 'z')
+		drop('A', 'Z')
+	}
+	if ok[' '] || ok['\n'] {
+		expected = append(expected, "whitespace")
+	}
+	for c := 0x21; c < 0x7f; c++ {
+		if ok[c] {
+			expected = append(expected, string(rune(c)))
+		}
+	}
+	return
+}
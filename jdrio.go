@@ -0,0 +1,332 @@
+package rdx
+
+import (
+	"bytes"
+	"io"
+)
+
+const jdrReadSize = 1 << 12
+
+// JDRDecoder parses JDR text from an io.Reader yielding top-level
+// elements one at a time. It runs the same machine as ParseJDR, keeping
+// its state across buffer refills, so the elements are byte-identical to
+// those ParseJDR produces for the whole text. A later semicolon may yet
+// wrap the preceding elements into a tuple (`1 2\n3;` is `(1 2 3)`), so
+// elements are yielded once a top-level comma or semicolon ends their
+// group, or at the end of the input. The text is kept only as long as
+// the token being lexed needs it; the memory use is bounded by the
+// largest group of top-level elements, not by the size of the input.
+// Elements are not normalized, same as ParseJDR.
+type JDRDecoder struct {
+	r    io.Reader
+	buf  []byte
+	p    int
+	rdx  []byte
+	rec  Stream
+	err  error
+	eof  bool
+	done bool
+
+	// the position of buf[0] in the input, for errors
+	off, line, col int
+
+	cs    int
+	state JDRstate
+	marks [len(jdrTokens)]int
+	live  uint64 // the tokens whose text is still needed
+}
+
+// jdrTokens lists the callbacks in the order of the actions of the
+// machine: action 2k marks the start of the token k, 2k+1 calls back.
+// That is the order of the tokens in JDR.go.rl; once the machine gets
+// regenerated, TestJDRTokens checks the table against JDR.rl.go.
+var jdrTokens = [...]struct {
+	on   func(tok []byte, state *JDRstate) error
+	keep bool // the callback needs the text of the token
+	done bool // all the tokens before this one are complete
+}{
+	{JDRonNL, false, false},
+	{JDRonUtf8cp1, false, false},
+	{JDRonUtf8cp2, true, false},
+	{JDRonUtf8cp3, true, false},
+	{JDRonUtf8cp4, true, false},
+	{JDRonInt, true, false},
+	{JDRonFloat, true, false},
+	{JDRonTerm, true, false},
+	{JDRonRef, true, false},
+	{JDRonString, true, false},
+	{JDRonMLString, true, false},
+	{JDRonStamp, true, false},
+	{JDRonNoStamp, false, false},
+	{JDRonOpenP, false, false},
+	{JDRonCloseP, true, false},
+	{JDRonOpenL, false, false},
+	{JDRonCloseL, true, false},
+	{JDRonOpenE, false, false},
+	{JDRonCloseE, true, false},
+	{JDRonOpenX, false, false},
+	{JDRonCloseX, true, false},
+	{JDRonComma, false, false},
+	{JDRonColon, false, false},
+	{JDRonSemicolon, false, false},
+	{JDRonOpen, false, true},
+	{JDRonClose, false, true},
+	{JDRonInter, false, true},
+	{JDRonFIRST, true, true},
+	{JDRonRoot, false, false},
+}
+
+const jdrFIRSTToken = 27
+
+// NewJDRDecoder makes a decoder reading JDR text from r. To get the
+// elements streamed, the text must separate those with commas or
+// semicolons at the top level, as JDREncoder does. Whitespace is not
+// enough: a semicolon may come later to group the elements before it,
+// so an all-whitespace-separated text is buffered till its end.
+func NewJDRDecoder(r io.Reader) *JDRDecoder {
+	d := &JDRDecoder{
+		r:   r,
+		buf: make([]byte, 0, jdrReadSize),
+		cs:  JDR_start,
+	}
+	d.state.stack = make(Marks, 0, MaxNesting+1)
+	d.state.stack = append(d.state.stack, Mark{Lit: ' '})
+	return d
+}
+
+func (d *JDRDecoder) Read() bool {
+	for {
+		if len(d.rdx) > 0 {
+			_, _, _, rest, err := ReadRDX(d.rdx)
+			if err != nil {
+				d.err = err
+				d.rdx = nil
+				return false
+			}
+			d.rec = d.rdx[:len(d.rdx)-len(rest)]
+			d.rdx = rest
+			return true
+		}
+		d.rec = nil
+		if d.err != nil || d.done {
+			return false
+		}
+		switch {
+		case d.p < len(d.buf):
+			if err := d.lex(); err != nil {
+				d.err = d.check(err)
+			} else {
+				d.yield(d.state.stack[0].LastBreak)
+			}
+		case !d.eof:
+			d.fill()
+		default:
+			d.done = true
+			err := d.act(int(_JDR_eof_actions[d.cs]))
+			if err != nil {
+				d.p++
+			}
+			if d.err = d.check(err); d.err == nil {
+				d.yield(len(d.state.rdx))
+			}
+		}
+	}
+}
+
+// lex runs the machine over the buffered text
+func (d *JDRDecoder) lex() (err error) {
+	for ; d.p < len(d.buf); d.p++ {
+		trans := jdrLexerTrans(d.cs, d.buf[d.p])
+		cs := int(_JDR_trans_targs[trans])
+		if cs == JDR_error {
+			return ErrBadJDRSyntax // d.cs stays before the offending byte
+		}
+		d.cs = cs
+		if err = d.act(int(_JDR_trans_actions[trans])); err != nil {
+			d.p++ // a callback fails past its token
+			return err
+		}
+	}
+	return nil
+}
+
+// act runs a list of actions of the machine, see jdrTokens
+func (d *JDRDecoder) act(acts int) (err error) {
+	n := int(_JDR_actions[acts])
+	for _, a := range _JDR_actions[acts+1 : acts+1+n] {
+		k := a >> 1
+		tok := &jdrTokens[k]
+		if a&1 == 0 {
+			d.marks[k] = d.p
+			if tok.keep {
+				d.live |= 1 << k
+			}
+			continue
+		}
+		if err = tok.on(d.buf[d.marks[k]:d.p], &d.state); err != nil {
+			return
+		}
+		if tok.done {
+			for j, mark := range d.marks {
+				if mark < d.p {
+					d.live &^= 1 << j
+				}
+			}
+		}
+	}
+	return
+}
+
+// yield hands over the first n bytes of the parsed RDX: the top-level
+// elements no text to come may change
+func (d *JDRDecoder) yield(n int) {
+	if n == 0 {
+		return
+	}
+	d.rdx = d.state.rdx[:n:n]
+	d.state.rdx = append([]byte(nil), d.state.rdx[n:]...)
+	for i := range d.state.stack {
+		m := &d.state.stack[i]
+		m.Start = max(m.Start-n, 0)
+		m.LastBreak = max(m.LastBreak-n, 0)
+		m.LastElement = max(m.LastElement-n, 0)
+	}
+}
+
+// check reports an error the way JDRlexer and ParseJDR do, its
+// position counted from the start of the input
+func (d *JDRDecoder) check(err error) error {
+	if d.p >= len(d.buf) && !d.eof {
+		// is that the end of the input?
+		var one [1]byte
+		for {
+			n, e := d.r.Read(one[:])
+			if e == io.EOF {
+				d.eof = true
+			}
+			if n > 0 || e != nil {
+				break
+			}
+		}
+	}
+	end := d.p == len(d.buf) && d.eof
+	if end && d.cs < JDR_first_final {
+		err = ErrIncomplete
+	} else if !end || d.cs < JDR_first_final || err != nil {
+		d.state.jdr = d.buf[min(d.p, len(d.buf)):]
+		if err == nil {
+			err = ErrBadJDRSyntax
+		}
+	}
+	if err == nil && (len(d.state.stack) != 1 || d.state.stack[0].Lit != ' ') {
+		d.state.bad = d.buf[len(d.buf):] // unclosed at the end
+		err = ErrBadJDRNesting
+	}
+	if err == nil {
+		return nil
+	}
+	e := newJDRSyntaxError(d.buf, &d.state, err)
+	if err == ErrBadJDRSyntax || err == ErrIncomplete {
+		e.Expected = jdrExpected(d.cs)
+	}
+	if e.Line == 1 {
+		e.Col += d.col
+	}
+	e.Line += d.line
+	e.Offset += d.off
+	return e
+}
+
+// advance moves the input position past the dropped text
+func (d *JDRDecoder) advance(text []byte) {
+	d.off += len(text)
+	if nl := bytes.LastIndexByte(text, '\n'); nl >= 0 {
+		d.line += bytes.Count(text, []byte{'\n'})
+		d.col = len(text) - nl - 1
+	} else {
+		d.col += len(text)
+	}
+}
+
+func (d *JDRDecoder) Record() Stream {
+	return d.rec
+}
+
+func (d *JDRDecoder) Parsed() (lit byte, id ID, value []byte) {
+	lit, id, value, _, _ = ReadRDX(d.rec)
+	return
+}
+
+func (d *JDRDecoder) Error() error {
+	return d.err
+}
+
+// fill drops the text no token needs anymore, then reads more
+func (d *JDRDecoder) fill() {
+	from := d.p
+	for k, mark := range d.marks {
+		if d.live&(1<<k) != 0 {
+			from = min(from, mark)
+		}
+	}
+	val := -1 // the value of the FIRST element being lexed
+	if d.live&(1<<jdrFIRSTToken) != 0 && d.state.val != nil {
+		val = cap(d.buf) - cap(d.state.val)
+	}
+	buf := d.buf[:0]
+	if cap(d.buf)-len(d.buf)+from < jdrReadSize/2 {
+		buf = make([]byte, 0, cap(d.buf)*2)
+	}
+	d.advance(d.buf[:from])
+	buf = buf[:copy(buf[:cap(buf)], d.buf[from:])]
+	for k := range d.marks {
+		d.marks[k] = max(d.marks[k]-from, 0)
+	}
+	d.p -= from
+	if val >= from {
+		d.state.val = buf[val-from : val-from+len(d.state.val)]
+	} else {
+		d.state.val = nil
+	}
+	d.buf = buf
+	n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+	d.buf = d.buf[:len(d.buf)+n]
+	if err == io.EOF {
+		d.eof = true
+	} else if err != nil {
+		d.err = err
+	}
+}
+
+// JDREncoder renders RDX elements to an io.Writer as JDR text,
+// one top-level element per line. Each element ends with a comma, so
+// JDRDecoder yields it as soon as it is read back.
+type JDREncoder struct {
+	w     io.Writer
+	style Style
+	buf   []byte
+}
+
+func NewJDREncoder(w io.Writer, style Style) *JDREncoder {
+	return &JDREncoder{w: w, style: style}
+}
+
+// Encode writes all the elements of the stream.
+func (e *JDREncoder) Encode(rdx Stream) (err error) {
+	it := NewIter(rdx)
+	for it.Read() && err == nil {
+		err = e.WriteRecord(it.Record())
+	}
+	if err == nil && it.HasFailed() {
+		err = it.Error()
+	}
+	return
+}
+
+// WriteRecord writes one element.
+func (e *JDREncoder) WriteRecord(rec Stream) (err error) {
+	e.buf = renderJDRList(e.buf[:0], NewIter(rec), e.style)
+	e.buf = append(bytes.Trim(e.buf, " \t\r\n"), ',', '\n')
+	_, err = e.w.Write(e.buf)
+	return
+}
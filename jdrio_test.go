@@ -0,0 +1,186 @@
+package rdx
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeJDR(r io.Reader) (rdx []byte, err error) {
+	dec := NewJDRDecoder(r)
+	for dec.Read() {
+		rdx = append(rdx, dec.Record()...)
+	}
+	return rdx, dec.Error()
+}
+
+func TestJDRDecoder(t *testing.T) {
+	cases := []string{
+		"1 2 3",
+		"1 2\n3;",
+		"1:2:3 4:5:6 ()",
+		"1 2 3; 4:5:6; ;",
+		"1,,2, 3\n4",
+		"{a:1, b:[2 3]}\n{c:\"x,\\\"y\\n\"} `multi\nline`\n",
+		"one@alice-2\n\"two\"@bob-4",
+		"a:\nb c\n:d",
+		"<1@alice-1,2@bob-2>\n\n[1 2]  ",
+		"1.5e3 @alice-4, \"\u00e9\xd0\x96\", ,",
+		"",
+		"  \n ",
+	}
+	for _, c := range cases {
+		want, err := ParseJDR([]byte(c))
+		assert.Nil(t, err, c)
+		got, err := decodeJDR(iotest.OneByteReader(strings.NewReader(c)))
+		assert.Nil(t, err, c)
+		assert.Equal(t, string(want), string(got), c)
+	}
+
+	dec := NewJDRDecoder(strings.NewReader("1, 2,\n{3"))
+	assert.True(t, dec.Read())
+	assert.True(t, dec.Read())
+	assert.False(t, dec.Read())
+	assert.ErrorIs(t, dec.Error(), ErrBadJDRNesting)
+
+	// a comma-separated element is yielded as soon as it is read,
+	// a whitespace-separated one waits for the end of the input
+	dec = NewJDRDecoder(iotest.OneByteReader(strings.NewReader("1, 2, 3")))
+	assert.True(t, dec.Read())
+	assert.False(t, dec.eof)
+	dec = NewJDRDecoder(iotest.OneByteReader(strings.NewReader("1 2\n3")))
+	assert.True(t, dec.Read())
+	assert.True(t, dec.eof)
+	assert.True(t, dec.Read())
+	assert.True(t, dec.Read())
+	assert.False(t, dec.Read())
+	assert.Nil(t, dec.Error())
+
+	bad := []string{
+		"1, 2\n[3 4}, 5",
+		"1 2\n{3",
+		"{a:1,\n b:@}",
+		"1, \"abc",
+		"1 2 3 )",
+		"[1 2]\n\"\xed\xa0\x80\"",
+		"\"\xed\xa0\x80",
+		"x:y, 1@alice-",
+	}
+	for _, c := range bad {
+		_, err := ParseJDR([]byte(c))
+		var want, got *JDRSyntaxError
+		assert.ErrorAs(t, err, &want, c)
+		for _, r := range []io.Reader{
+			strings.NewReader(c),
+			iotest.OneByteReader(strings.NewReader(c)),
+		} {
+			_, err = decodeJDR(r)
+			if !assert.ErrorAs(t, err, &got, c) {
+				continue
+			}
+			assert.Equal(t, want.Err, got.Err, c)
+			assert.Equal(t, want.Offset, got.Offset, c)
+			assert.Equal(t, want.Line, got.Line, c)
+			assert.Equal(t, want.Col, got.Col, c)
+			assert.Equal(t, want.Expected, got.Expected, c)
+			assert.Equal(t, want.Open, got.Open, c)
+		}
+	}
+}
+
+// TestJDRTokens checks jdrTokens against the actions of the generated machine
+func TestJDRTokens(t *testing.T) {
+	src, err := os.ReadFile("JDR.rl.go")
+	assert.Nil(t, err)
+	re := regexp.MustCompile(`case (\d+):\s+(?:mark0\[JDR(\w+)\] = p|err = JDRon(\w+)\()`)
+	seen := make(map[int]bool)
+	for _, act := range re.FindAllSubmatch(src, -1) {
+		a, err := strconv.Atoi(string(act[1]))
+		assert.Nil(t, err)
+		seen[a] = true
+		if !assert.Less(t, a/2, len(jdrTokens), "action %d", a) {
+			continue
+		}
+		name := string(act[2])
+		if a&1 == 1 {
+			name = string(act[3])
+		}
+		assert.NotEmpty(t, name, "action %d", a)
+		fn := runtime.FuncForPC(reflect.ValueOf(jdrTokens[a/2].on).Pointer()).Name()
+		assert.Equal(t, "github.com/gritzko/rdx.JDRon"+name, fn, "action %d", a)
+	}
+	assert.Equal(t, 2*len(jdrTokens), len(seen))
+	assert.Equal(t, "github.com/gritzko/rdx.JDRonFIRST",
+		runtime.FuncForPC(reflect.ValueOf(jdrTokens[jdrFIRSTToken].on).Pointer()).Name())
+}
+
+func TestJDRDecoderCorpus(t *testing.T) {
+	files, err := filepath.Glob("*.md")
+	assert.Nil(t, err)
+	for _, file := range files {
+		jdr, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		want, err := ParseJDR(jdr)
+		if err != nil {
+			continue // not a test file
+		}
+		got, err := decodeJDR(iotest.OneByteReader(bytes.NewReader(jdr)))
+		assert.Nil(t, err, file)
+		assert.Equal(t, string(want), string(got), file)
+		got, err = decodeJDR(bytes.NewReader(jdr))
+		assert.Nil(t, err, file)
+		assert.Equal(t, string(want), string(got), file)
+	}
+}
+
+func TestJDRDecoderMemory(t *testing.T) {
+	// a long stream of elements, a long string among those
+	long := "\"" + strings.Repeat("long ", 1<<12) + "\""
+	text := strings.Repeat("{a:1, b:[2 3]},\n", 1<<14) + long + strings.Repeat(",\n1 2;", 1<<14)
+	dec := NewJDRDecoder(strings.NewReader(text))
+	n, most := 0, 0
+	for dec.Read() {
+		n++
+		most = max(most, cap(dec.buf))
+		assert.Less(t, len(dec.state.rdx), 64)
+	}
+	assert.Nil(t, dec.Error())
+	assert.Equal(t, 1<<15+1, n)
+	assert.Less(t, most, len(long)*2)
+}
+
+func TestJDREncoder(t *testing.T) {
+	doc := "{a:1, b:(2 3)} x:y \"z\"@alice-4 [1 2 3] (tag one two)"
+	rdx, err := ParseNormalizeJDR([]byte(doc))
+	assert.Nil(t, err)
+	styles := []Style{
+		0,
+		StyleStamps,
+		JDRNormalStyle | StyleStamps,
+		NewStyle(StyleYell, StyleStamps, StyleUseComma, StyleUseLF),
+	}
+	for _, style := range styles {
+		var out bytes.Buffer
+		assert.Nil(t, NewJDREncoder(&out, style).Encode(rdx))
+		dec := NewJDRDecoder(&out)
+		var back []byte
+		for dec.Read() {
+			back = append(back, dec.Record()...)
+		}
+		assert.Nil(t, dec.Error())
+		norm, err := Normalize(back)
+		assert.Nil(t, err)
+		assert.Equal(t, string(RenderJDR(rdx, StyleStamps)),
+			string(RenderJDR(norm, StyleStamps)))
+	}
+}
@@ -0,0 +1,414 @@
+package rdx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// JSON to RDX mapping:
+//
+//	JSON                 RDX
+//	{"key": value, ...}  Eulerian map of (key value) tuples, see ObjectReader;
+//	                     keys are Terms if those are valid JDR Terms,
+//	                     Strings otherwise
+//	[1, 2, 3]            Linear container
+//	true false null      Terms
+//	12                   Integer (if it fits int64)
+//	1.5 1e6              Float
+//	"text"               String
+//
+// Export does the reverse. Multix containers, tuples, sets, References,
+// non-JSON Terms and stamps have no JSON equivalent; those fail the strict
+// export. The lossy export renders those as arrays or strings, drops
+// stamps and skips tombstones.
+
+var (
+	ErrJSONStamp   = errors.New("stamps do not map to JSON")
+	ErrJSONType    = errors.New("element type does not map to JSON")
+	ErrBadJSONType = errors.New("unexpected JSON token")
+)
+
+// JSONReader converts a stream of JSON values into normalized RDX elements,
+// one top-level value at a time. A value is buffered whole, so a dump
+// that is one huge top-level array needs NewJSONArrayReader instead.
+type JSONReader struct {
+	dec    *json.Decoder
+	raw    []byte
+	rec    Stream
+	stack  Marks
+	err    error
+	array  bool // yield the elements of a top-level array
+	inside bool
+}
+
+func NewJSONReader(r io.Reader) *JSONReader {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &JSONReader{
+		dec:   dec,
+		stack: make(Marks, 0, MaxNesting+1),
+	}
+}
+
+// NewJSONArrayReader reads a single top-level JSON array, yielding its
+// elements one at a time, as if those were top-level values. The memory
+// use is bounded by the largest element, not by the whole array.
+func NewJSONArrayReader(r io.Reader) *JSONReader {
+	j := NewJSONReader(r)
+	j.array = true
+	return j
+}
+
+func (j *JSONReader) Read() bool {
+	if j.err != nil {
+		return false
+	}
+	j.rec = nil
+	if j.array && !j.inside {
+		tok, err := j.dec.Token()
+		if err != nil {
+			j.err = err
+			return false
+		} else if tok != json.Delim('[') {
+			j.err = ErrBadJSONType
+			return false
+		}
+		j.inside = true
+	}
+	if !j.dec.More() {
+		if j.array {
+			if _, j.err = j.dec.Token(); j.err != nil { // ]
+				return false
+			}
+			j.array = false
+		}
+		_, err := j.dec.Token()
+		if err == nil {
+			j.err = ErrBadJSONType
+		} else if err != io.EOF {
+			j.err = fmt.Errorf("%w: %w", ErrBadJSONType, err)
+		}
+		return false
+	}
+	j.stack = j.stack[:0]
+	j.raw, j.err = j.appendValue(j.raw[:0])
+	if j.err == nil {
+		j.rec, j.err = Normalize(j.raw)
+	}
+	return j.err == nil
+}
+
+func (j *JSONReader) Record() Stream {
+	return j.rec
+}
+
+func (j *JSONReader) Parsed() (lit byte, id ID, value []byte) {
+	it := NewIter(j.rec)
+	it.Read()
+	return it.Parsed()
+}
+
+func (j *JSONReader) Error() error {
+	return j.err
+}
+
+func isJDRTerm(key string) bool {
+	if len(key) == 0 || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if RON64REV[key[i]] == 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+func appendJSONNumber(data []byte, num json.Number) ([]byte, error) {
+	if i, err := strconv.ParseInt(string(num), 10, 64); err == nil {
+		return WriteRDX(data, LitInteger, ID0, ZipInt64(i)), nil
+	}
+	f, err := strconv.ParseFloat(string(num), 64)
+	if err != nil { // out of the float64 range too
+		return nil, err
+	}
+	return WriteRDX(data, LitFloat, ID0, ZipFloat64(f)), nil
+}
+
+func (j *JSONReader) open(data []byte, lit byte) ([]byte, error) {
+	if len(j.stack) >= MaxNesting {
+		return nil, ErrBadNesting
+	}
+	data = OpenTLV(data, lit, &j.stack)
+	return append(data, 0), nil
+}
+
+func (j *JSONReader) appendValue(data []byte) (rdx []byte, err error) {
+	tok, err := j.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	rdx = data
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			if rdx, err = j.open(rdx, LitEuler); err != nil {
+				return
+			}
+			for j.dec.More() && err == nil {
+				tok, err = j.dec.Token()
+				key, ok := tok.(string)
+				if err != nil {
+					break
+				} else if !ok {
+					return nil, ErrBadJSONType
+				}
+				rdx = OpenShortTLV(rdx, LitTuple, &j.stack)
+				rdx = append(rdx, 0)
+				if isJDRTerm(key) {
+					rdx = WriteRDX(rdx, LitTerm, ID0, []byte(key))
+				} else {
+					rdx = WriteRDX(rdx, LitString, ID0, []byte(key))
+				}
+				rdx, err = j.appendValue(rdx)
+				if err == nil {
+					rdx, err = CloseTLV(rdx, LitTuple, &j.stack)
+				}
+			}
+			if err == nil {
+				_, err = j.dec.Token()
+			}
+			if err == nil {
+				rdx, err = CloseTLV(rdx, LitEuler, &j.stack)
+			}
+		case '[':
+			if rdx, err = j.open(rdx, LitLinear); err != nil {
+				return
+			}
+			for j.dec.More() && err == nil {
+				rdx, err = j.appendValue(rdx)
+			}
+			if err == nil {
+				_, err = j.dec.Token()
+			}
+			if err == nil {
+				rdx, err = CloseTLV(rdx, LitLinear, &j.stack)
+			}
+		default:
+			err = ErrBadJSONType
+		}
+	case string:
+		rdx = WriteRDX(rdx, LitString, ID0, []byte(v))
+	case json.Number:
+		rdx, err = appendJSONNumber(rdx, v)
+	case bool:
+		if v {
+			rdx = WriteRDX(rdx, LitTerm, ID0, []byte("true"))
+		} else {
+			rdx = WriteRDX(rdx, LitTerm, ID0, []byte("false"))
+		}
+	case nil:
+		rdx = WriteRDX(rdx, LitTerm, ID0, []byte("null"))
+	default:
+		err = ErrBadJSONType
+	}
+	return
+}
+
+// FromJSON converts a sequence of JSON values into a sequence of RDX
+// elements. The result is collected in memory; JSONReader and
+// NewJSONArrayReader convert a dump element by element.
+func FromJSON(r io.Reader) (rdx Stream, err error) {
+	reader := NewJSONReader(r)
+	for reader.Read() {
+		rdx = append(rdx, reader.Record()...)
+	}
+	return rdx, reader.Error()
+}
+
+// ToJSON writes top-level RDX elements as JSON values, one per line.
+// Fails on anything JSON can not hold.
+func ToJSON(rdx Stream, w io.Writer) error {
+	return writeJSON(rdx, w, false)
+}
+
+// ToJSONLossy is ToJSON that drops metadata and tombstones and renders
+// non-JSON elements as arrays or strings.
+func ToJSONLossy(rdx Stream, w io.Writer) error {
+	return writeJSON(rdx, w, true)
+}
+
+func writeJSON(rdx Stream, w io.Writer, lossy bool) (err error) {
+	it := NewIter(rdx)
+	var buf []byte
+	for it.Read() && err == nil {
+		if lossy && !it.IsLive() {
+			continue
+		}
+		buf, err = appendJSON(buf[:0], &it, lossy)
+		if err == nil {
+			buf = append(buf, '\n')
+			_, err = w.Write(buf)
+		}
+	}
+	if err == nil && it.HasFailed() {
+		err = it.Error()
+	}
+	return
+}
+
+func isJSONObject(it *Iter, lossy bool) bool {
+	in := it.Inner()
+	for in.Read() {
+		if lossy && !in.IsLive() {
+			continue
+		}
+		if in.Lit() != LitTuple {
+			return false
+		}
+		kv := in.Inner()
+		n := 0
+		for kv.Read() {
+			if n == 0 && kv.Lit() != LitTerm && kv.Lit() != LitString {
+				return false
+			}
+			n++
+		}
+		if n != 2 {
+			return false
+		}
+	}
+	return true
+}
+
+func appendJSONString(json []byte, val []byte) []byte {
+	const hex = "0123456789abcdef"
+	json = append(json, '"')
+	for _, c := range val {
+		switch c {
+		case '"':
+			json = append(json, '\\', '"')
+		case '\\':
+			json = append(json, '\\', '\\')
+		case '\n':
+			json = append(json, '\\', 'n')
+		case '\r':
+			json = append(json, '\\', 'r')
+		case '\t':
+			json = append(json, '\\', 't')
+		default:
+			if c < 0x20 {
+				json = append(json, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				json = append(json, c)
+			}
+		}
+	}
+	return append(json, '"')
+}
+
+func appendJSONList(json []byte, it *Iter, lossy bool) (res []byte, err error) {
+	in := it.Inner()
+	n := 0
+	res = append(json, '[')
+	for in.Read() && err == nil {
+		if lossy && !in.IsLive() {
+			continue
+		}
+		if n > 0 {
+			res = append(res, ',')
+		}
+		res, err = appendJSON(res, &in, lossy)
+		n++
+	}
+	return append(res, ']'), err
+}
+
+func appendJSONObject(json []byte, it *Iter, lossy bool) (res []byte, err error) {
+	in := it.Inner()
+	n := 0
+	res = append(json, '{')
+	for in.Read() && err == nil {
+		if lossy && !in.IsLive() {
+			continue
+		} else if !lossy && !in.ID().IsZero() {
+			return nil, ErrJSONStamp
+		}
+		kv := in.Inner()
+		kv.Read()
+		if !lossy && !kv.ID().IsZero() {
+			return nil, ErrJSONStamp
+		}
+		if n > 0 {
+			res = append(res, ',')
+		}
+		res = appendJSONString(res, kv.Value())
+		res = append(res, ':')
+		kv.Read()
+		res, err = appendJSON(res, &kv, lossy)
+		n++
+	}
+	return append(res, '}'), err
+}
+
+func appendJSON(json []byte, it *Iter, lossy bool) (res []byte, err error) {
+	if !lossy && !it.ID().IsZero() {
+		return nil, ErrJSONStamp
+	}
+	res = json
+	switch it.Lit() {
+	case LitFloat:
+		f := float64(it.Float())
+		if math.IsInf(f, 0) {
+			return nil, ErrJSONType
+		}
+		l := len(res)
+		res = strconv.AppendFloat(res, f, 'g', -1, 64)
+		if !bytes.ContainsAny(res[l:], ".eE") {
+			res = append(res, '.', '0')
+		}
+	case LitInteger:
+		res = strconv.AppendInt(res, int64(it.Integer()), 10)
+	case LitReference:
+		if !lossy {
+			return nil, ErrJSONType
+		}
+		res = appendJSONString(res, it.Reference().RonString())
+	case LitString:
+		res = appendJSONString(res, it.Value())
+	case LitTerm:
+		switch string(it.Value()) {
+		case "true", "false", "null":
+			res = append(res, it.Value()...)
+		default:
+			if !lossy {
+				return nil, ErrJSONType
+			}
+			res = appendJSONString(res, it.Value())
+		}
+	case LitLinear:
+		res, err = appendJSONList(res, it, lossy)
+	case LitEuler:
+		if isJSONObject(it, lossy) {
+			res, err = appendJSONObject(res, it, lossy)
+		} else if lossy {
+			res, err = appendJSONList(res, it, lossy)
+		} else {
+			err = ErrJSONType
+		}
+	case LitTuple, LitMultix:
+		if !lossy {
+			return nil, ErrJSONType
+		}
+		res, err = appendJSONList(res, it, lossy)
+	default:
+		err = ErrBadRecord
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package rdx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	cases := []string{
+		`{"first name":"Alice","a":1,"b":[true,false,null]}`,
+		`[1,2.5,-3,"x\ny\"z",{}]`,
+		`1e+100`,
+		`{"nested":{"deep":{"x":[[]]}}}`,
+	}
+	for _, c := range cases {
+		rdx, err := FromJSON(strings.NewReader(c))
+		assert.Nil(t, err, c)
+		var out bytes.Buffer
+		err = ToJSON(rdx, &out)
+		assert.Nil(t, err, c)
+		assert.Equal(t, c+"\n", out.String())
+	}
+}
+
+func TestJSONMapping(t *testing.T) {
+	rdx, err := FromJSON(strings.NewReader(`{"b":2, "a":1, "with space": 3.0} [1] "s"`))
+	assert.Nil(t, err)
+	jdr, err := ParseNormalizeJDR([]byte(`{a:1, b:2, "with space":3.0} [1] "s"`))
+	assert.Nil(t, err)
+	assert.Equal(t, jdr, rdx)
+
+	o, err := NewObjectReader(rdx)
+	assert.Nil(t, err)
+	keys := []string{}
+	for o.Read() {
+		keys = append(keys, o.Key)
+	}
+	assert.Equal(t, []string{"with space", "a", "b"}, keys)
+
+	_, err = FromJSON(strings.NewReader(`{"a":1,`))
+	assert.NotNil(t, err)
+
+	// no clamping of the numbers out of the float64 range
+	for _, num := range []string{`1e400`, `[-1e400]`} {
+		_, err = FromJSON(strings.NewReader(num))
+		assert.ErrorIs(t, err, strconv.ErrRange, num)
+	}
+}
+
+func TestJSONArrayReader(t *testing.T) {
+	r := NewJSONArrayReader(strings.NewReader(`[{"b":2, "a":1}, [1], "s"]`))
+	var rdx Stream
+	for r.Read() {
+		rdx = append(rdx, r.Record()...)
+	}
+	assert.Nil(t, r.Error())
+	jdr, err := ParseNormalizeJDR([]byte(`{a:1, b:2} [1] "s"`))
+	assert.Nil(t, err)
+	assert.Equal(t, jdr, rdx)
+
+	// a long array goes element by element
+	const n = 1 << 16
+	parts := []io.Reader{strings.NewReader("[")}
+	for i := 0; i < n; i++ {
+		parts = append(parts, strings.NewReader(`{"id":`+strconv.Itoa(i)+`, "tags":["x","y"]},`))
+	}
+	parts = append(parts, strings.NewReader(`{}]`))
+	r = NewJSONArrayReader(io.MultiReader(parts...))
+	count, most := 0, 0
+	for r.Read() {
+		count++
+		most = max(most, cap(r.raw))
+	}
+	assert.Nil(t, r.Error())
+	assert.Equal(t, n+1, count)
+	assert.Less(t, most, 256)
+
+	for _, bad := range []string{`{"a":1}`, `[1, 2] 3`, `[1, 2`, ``} {
+		r = NewJSONArrayReader(strings.NewReader(bad))
+		for r.Read() {
+		}
+		assert.NotNil(t, r.Error(), bad)
+	}
+
+	// the cause of a failure at the end stays
+	r = NewJSONReader(strings.NewReader(`1 }`))
+	for r.Read() {
+	}
+	var syntax *json.SyntaxError
+	assert.ErrorIs(t, r.Error(), ErrBadJSONType)
+	assert.ErrorAs(t, r.Error(), &syntax)
+	r = NewJSONReader(io.MultiReader(strings.NewReader(`1 `), iotest.ErrReader(io.ErrUnexpectedEOF)))
+	for r.Read() {
+	}
+	assert.ErrorIs(t, r.Error(), io.ErrUnexpectedEOF)
+}
+
+func TestJSONExportErrors(t *testing.T) {
+	cases := map[string]error{
+		"1@a-2":       ErrJSONStamp,
+		"<1>":         ErrJSONType,
+		"1:2":         ErrJSONType,
+		"{1 2}":       ErrJSONType,
+		"bob-1":       ErrJSONType,
+		"kg":          ErrJSONType,
+		"[1@alice-2]": ErrJSONStamp,
+	}
+	for jdr, want := range cases {
+		rdx, err := ParseNormalizeJDR([]byte(jdr))
+		assert.Nil(t, err)
+		var out bytes.Buffer
+		assert.Equal(t, want, ToJSON(rdx, &out), jdr)
+	}
+	rdx, _ := ParseNormalizeJDR([]byte(`{a:<1@a-2>, b:kg} [1@alice-2 2@alice-3]`))
+	var out bytes.Buffer
+	assert.Nil(t, ToJSONLossy(rdx, &out))
+	assert.Equal(t, "{\"a\":[1],\"b\":\"kg\"}\n[1]\n", out.String())
+}
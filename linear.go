@@ -0,0 +1,377 @@
+package rdx
+
+import "errors"
+
+var (
+	ErrNoRoom      = errors.New("no locator space for the Linear insertion")
+	ErrBadPosition = errors.New("position out of range")
+	ErrNotLinear   = errors.New("not a Linear element")
+)
+
+// LinearEditor produces DISCONT patches for a Linear element, see
+// DISCONT.md. Positions count live elements only. Every method returns
+// a patch (a Linear element with the same ID) and applies it to the
+// editor's own copy of the document, so positions stay consistent.
+//
+// New elements get locators between their neighbors. If there is no
+// room, an insertion train is made: the head goes between the neighbors,
+// the tail gets locators lower than the left neighbor. Prepends have no
+// left neighbor, so they go right under the first element, and a train
+// tail goes under its own head. If the merge order
+// can not be inferred from the locators alone (trains, zero IDs), the
+// patch cites the preceding elements as a context.
+//
+// The editor takes the replica's Src, not a Clock: the Seq of a Linear
+// element is its locator, picked by the position, not by the time.
+type LinearEditor struct {
+	doc Stream
+	id  ID
+	src uint64
+	// trains makes multi-element inserts always go as trains, so
+	// concurrent runs do not interleave (that matters for text)
+	trains bool
+}
+
+func NewLinearEditor(doc Stream, src uint64) (*LinearEditor, error) {
+	it := NewIter(doc)
+	if !it.Read() || it.Lit() != LitLinear {
+		return nil, ErrNotLinear
+	}
+	return &LinearEditor{
+		doc: append(Stream(nil), it.Record()...),
+		id:  it.ID(),
+		src: src,
+	}, nil
+}
+
+// Doc returns the current version of the document.
+func (e *LinearEditor) Doc() Stream {
+	return e.doc
+}
+
+// Merge merges a patch made by another replica (or the whole document
+// of one) into the editor's copy, so the edits of all the replicas
+// converge. The result must stay a single Linear element.
+func (e *LinearEditor) Merge(patch Stream) error {
+	doc, err := Merge(nil, [][]byte{e.doc, patch})
+	if err != nil {
+		return err
+	}
+	it := NewIter(doc)
+	if !it.Read() || it.Lit() != LitLinear || len(it.Record()) != len(doc) {
+		return ErrNotLinear
+	}
+	e.doc, e.id = doc, it.ID()
+	return nil
+}
+
+// linearKey is the CompareLinear order as numbers: the locator
+// is rotated so plain integer comparison works, see Ron60.Less
+type linearKey struct {
+	pos uint64
+	src uint64
+}
+
+const linearTop = Mask60bit
+
+func keyOf(id ID) linearKey {
+	return linearKey{
+		pos: (uint64(NewRon60(id.Seq>>6)) + ron60bit) & Mask60bit,
+		src: id.Src,
+	}
+}
+
+func (a linearKey) less(b linearKey) bool {
+	return a.pos < b.pos || (a.pos == b.pos && a.src < b.src)
+}
+
+// seqOf converts a rotated position back into a Seq
+func seqOf(pos uint64) uint64 {
+	return Ron60((pos-ron60bit)&Mask60bit).Uint64() << 6
+}
+
+func (e *LinearEditor) elements() (els []Iter, live []int) {
+	it := NewIter(e.doc)
+	it.Read()
+	in := it.Inner()
+	for in.Read() {
+		if in.IsLive() {
+			live = append(live, len(els))
+		}
+		els = append(els, in)
+	}
+	return
+}
+
+// Len returns the number of live elements.
+func (e *LinearEditor) Len() int {
+	_, live := e.elements()
+	return len(live)
+}
+
+// linearContext returns the elements to cite before placing an element with
+// the key `first` at the index: nothing if `first` is greater than
+// everything before, otherwise everything from the last prefix maximum.
+func linearContext(els []Iter, idx int, first linearKey) (ctx []byte) {
+	j := 0
+	var top linearKey
+	for i := 0; i < idx; i++ {
+		k := keyOf(els[i].ID())
+		if i == 0 || top.less(k) {
+			top, j = k, i
+		}
+	}
+	if idx == 0 || top.less(first) {
+		return nil
+	}
+	for i := j; i < idx; i++ {
+		ctx = append(ctx, els[i].Record()...)
+	}
+	return
+}
+
+// unnormal tells positions that do not map back to themselves: a top
+// digit 0 is not normalized, ron60bit itself would give the Seq 0
+func unnormal(pos uint64) bool {
+	return pos >= ron60bit && pos < 2*ron60bit
+}
+
+// allocLinear picks n increasing positions in (lo, hi), the shortest
+// locators first, skipping those already used by this replica
+func allocLinear(n int, lo, hi uint64, used map[uint64]bool) (ps []uint64) {
+	for len(ps) < n {
+		p := uint64(0)
+		for g := 54; g >= 6 && p == 0; g -= 6 {
+			step := uint64(1) << g
+			for c := (lo/step + 1) * step; c < hi && c < linearTop; c += step {
+				if unnormal(c) {
+					c = 2*ron60bit - step
+				} else if !used[c] {
+					p = c
+					break
+				}
+			}
+		}
+		if p == 0 {
+			return nil
+		}
+		ps = append(ps, p)
+		lo = p
+	}
+	return
+}
+
+// allocBelow picks n increasing positions right under hi, for elements
+// that only have to sort before hi (prepends, train tails).
+// It starts one digit finer than the shortest locator available, so
+// the room under hi lasts for thousands of repeated prepends.
+func allocBelow(n int, hi uint64, used map[uint64]bool) (ps []uint64) {
+	ps = make([]uint64, n)
+	for i := n - 1; i >= 0; i-- {
+		top := min(hi, linearTop) - 1
+		if top >= ron60bit && top < 2*ron60bit {
+			top = ron60bit - 1
+		}
+		step := uint64(1) << 54
+		for step > 1<<6 && step > top {
+			step >>= 6
+		}
+		if step > 1<<6 {
+			step >>= 6
+		}
+		p := uint64(0)
+		for ; step >= 1<<6 && p == 0; step >>= 6 {
+			c := top / step * step
+			for k := 0; k < 64 && c > 0; k, c = k+1, c-step {
+				if unnormal(c) {
+					c = ron60bit
+				} else if !used[c] {
+					p = c
+					break
+				}
+			}
+		}
+		if p == 0 {
+			return nil
+		}
+		ps[i], hi = p, p
+	}
+	return ps
+}
+
+func (e *LinearEditor) patch(body []byte) Stream {
+	return WriteRDX(nil, LitLinear, e.id, body)
+}
+
+// apply makes a patch of the body, which is ctx bytes of context and
+// the records to put in place of the elements [from, till). It changes
+// the editor's copy the way a merge would, but without going through
+// the entire document.
+func (e *LinearEditor) apply(els []Iter, from, till int, body []byte, ctx int) Stream {
+	it := NewIter(e.doc)
+	it.Read()
+	old := it.Value()
+	offset := func(i int) int {
+		if i == len(els) {
+			return len(old)
+		}
+		return len(old) - len(els[i].data)
+	}
+	a, b := offset(from), offset(till)
+	doc := make([]byte, 0, len(old)-(b-a)+len(body)-ctx)
+	doc = append(doc, old[:a]...)
+	doc = append(doc, body[ctx:]...)
+	doc = append(doc, old[b:]...)
+	e.doc = e.patch(doc)
+	return e.patch(body)
+}
+
+func (e *LinearEditor) insert(els []Iter, idx int, elems []Stream) (patch Stream, err error) {
+	if len(elems) == 0 {
+		return nil, nil
+	}
+	src := e.src
+	used := make(map[uint64]bool)
+	for _, el := range els {
+		if k := keyOf(el.ID()); k.src == src {
+			used[k.pos] = true
+		}
+	}
+	lo, hi := uint64(0), uint64(linearTop)
+	if idx > 0 {
+		lo = keyOf(els[idx-1].ID()).pos
+	}
+	if idx < len(els) {
+		hi = keyOf(els[idx].ID()).pos
+	}
+	var ps []uint64
+	switch {
+	case idx == 0 && len(els) > 0:
+		// nothing on the left, so a train hangs its tail off its own head
+		if !e.trains || len(elems) == 1 {
+			ps = allocBelow(len(elems), hi, used)
+		} else if ps = allocBelow(1, hi, used); ps != nil {
+			tail := allocBelow(len(elems)-1, ps[0], used)
+			if tail == nil {
+				ps = nil
+			}
+			ps = append(ps, tail...)
+		}
+	case lo < hi:
+		if !e.trains || len(elems) == 1 {
+			ps = allocLinear(len(elems), lo, hi, used)
+		}
+		if ps == nil {
+			ps = allocLinear(1, lo, hi, used)
+			if ps != nil {
+				tail := allocBelow(len(elems)-1, lo, used)
+				if tail == nil {
+					ps = nil
+				}
+				ps = append(ps, tail...)
+			}
+		}
+		if ps == nil && e.trains {
+			ps = allocLinear(len(elems), lo, hi, used)
+		}
+	}
+	if ps == nil && idx > 0 {
+		ps = allocBelow(len(elems), min(lo, hi), used)
+	}
+	if ps == nil {
+		return nil, ErrNoRoom
+	}
+	body := linearContext(els, idx, linearKey{ps[0], src})
+	ctx := len(body)
+	for i, elem := range elems {
+		lit, _, val, _, err := ReadRDX(elem)
+		if err != nil {
+			return nil, err
+		}
+		body = WriteRDX(body, lit, ID{src, seqOf(ps[i])}, val)
+	}
+	return e.apply(els, idx, idx, body, ctx), nil
+}
+
+// InsertAt inserts elements before the live element at the position;
+// pos == Len() appends.
+func (e *LinearEditor) InsertAt(pos int, elems ...Stream) (patch Stream, err error) {
+	els, live := e.elements()
+	if pos < 0 || pos > len(live) {
+		return nil, ErrBadPosition
+	}
+	idx := len(els)
+	if pos < len(live) {
+		idx = live[pos]
+		for idx > 0 && !els[idx-1].IsLive() {
+			idx-- // go before the tombstones
+		}
+	}
+	return e.insert(els, idx, elems)
+}
+
+// InsertAfter inserts elements right after the element with the ID;
+// the zero ID means the start of the array.
+func (e *LinearEditor) InsertAfter(id ID, elems ...Stream) (patch Stream, err error) {
+	els, _ := e.elements()
+	if id.IsZero() {
+		return e.insert(els, 0, elems)
+	}
+	for i := range els {
+		if els[i].ID().Base() == id.Base() {
+			return e.insert(els, i+1, elems)
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+// DeleteAt tombstones the live element at the position.
+func (e *LinearEditor) DeleteAt(pos int) (patch Stream, err error) {
+	return e.DeleteRange(pos, 1)
+}
+
+// DeleteRange tombstones n live elements starting at the position.
+// The patch cites everything in between.
+func (e *LinearEditor) DeleteRange(pos, n int) (patch Stream, err error) {
+	els, live := e.elements()
+	if pos < 0 || n < 0 || pos+n > len(live) {
+		return nil, ErrBadPosition
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	idx := live[pos]
+	body := linearContext(els, idx, keyOf(els[idx].ID()))
+	ctx, i := len(body), idx
+	for k := pos; k < pos+n; i++ {
+		if i == live[k] {
+			body = appendTombstone(body, &els[i], LitLinear)
+			k++
+		} else {
+			body = append(body, els[i].Record()...)
+		}
+	}
+	return e.apply(els, idx, i, body, ctx), nil
+}
+
+// ReplaceAt overwrites the live element at the position, keeping its place.
+func (e *LinearEditor) ReplaceAt(pos int, elem Stream) (patch Stream, err error) {
+	els, live := e.elements()
+	if pos < 0 || pos >= len(live) {
+		return nil, ErrBadPosition
+	}
+	idx := live[pos]
+	old := els[idx].ID()
+	id := old.Recovered()
+	if id.Base() != old.Base() {
+		return nil, ErrRevisionOverflow
+	}
+	lit, _, val, _, err := ReadRDX(elem)
+	if err != nil {
+		return nil, err
+	}
+	body := linearContext(els, idx, keyOf(old))
+	ctx := len(body)
+	body = WriteRDX(body, lit, id, val)
+	return e.apply(els, idx, idx+1, body, ctx), nil
+}
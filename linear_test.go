@@ -0,0 +1,157 @@
+package rdx
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinearEditor(t *testing.T) {
+	doc, err := ParseJDR([]byte("[]"))
+	assert.Nil(t, err)
+	ed, err := NewLinearEditor(doc, 1)
+	assert.Nil(t, err)
+	check := func(patch Stream, err error, want string) {
+		assert.Nil(t, err)
+		merged, err := Merge(nil, [][]byte{doc, patch})
+		assert.Nil(t, err)
+		assert.Equal(t, want, flatJDR(t, merged), string(RenderJDR(patch, StyleStamps)))
+		assert.Equal(t, want, flatJDR(t, ed.Doc()))
+		doc = ed.Doc()
+	}
+	patch, err := ed.InsertAt(0, S0("a"), S0("b"), S0("c"))
+	check(patch, err, `["a" "b" "c"]`)
+	assert.Equal(t, 3, ed.Len())
+	patch, err = ed.InsertAt(1, S0("x"))
+	check(patch, err, `["a" "x" "b" "c"]`)
+	patch, err = ed.InsertAt(4, S0("z"))
+	check(patch, err, `["a" "x" "b" "c" "z"]`)
+	patch, err = ed.DeleteAt(2)
+	check(patch, err, `["a" "x" "c" "z"]`)
+	patch, err = ed.ReplaceAt(0, I0(1))
+	check(patch, err, `[1 "x" "c" "z"]`)
+	patch, err = ed.InsertAt(2, S0("y"))
+	check(patch, err, `[1 "x" "y" "c" "z"]`)
+	_, err = ed.DeleteAt(5)
+	assert.Equal(t, ErrBadPosition, err)
+}
+
+func TestLinearEditorTight(t *testing.T) {
+	cases := []struct {
+		doc, want string
+		pos       int
+	}{
+		{"[a@alice-1111111110 b@alice-1111111120]", "[a x y b]", 1},
+		{"[a@alice-10 b@bob-10 c@carol-10]", "[a b x y c]", 2},
+		{"[1 2 3]", "[1 x y 2 3]", 1},
+		{"[a@alice-20 h@alice-40 t@alice-10 c@bob-30]", "[a h t x y c]", 3},
+		{"[a@alice-20 h@alice-40 t@alice-10 c@bob-30]", "[a h x y t c]", 2},
+	}
+	for _, c := range cases {
+		doc, err := ParseJDR([]byte(c.doc))
+		assert.Nil(t, err)
+		ed, err := NewLinearEditor(doc, 7)
+		assert.Nil(t, err)
+		patch, err := ed.InsertAt(c.pos, T0("x"), T0("y"))
+		assert.Nil(t, err, c.doc)
+		merged, err := Merge(nil, [][]byte{doc, patch})
+		assert.Nil(t, err)
+		assert.Equal(t, c.want, flatJDR(t, merged),
+			c.doc+" patch "+string(RenderJDR(patch, StyleStamps)))
+		patch, err = ed.DeleteAt(c.pos + 2)
+		assert.Nil(t, err)
+		merged, err = Merge(nil, [][]byte{merged, patch})
+		assert.Nil(t, err)
+		assert.Equal(t, flatJDR(t, ed.Doc()), flatJDR(t, merged))
+		assert.Equal(t, len(strings.Fields(c.want))-1, ed.Len())
+	}
+}
+
+func TestLinearEditorConcurrent(t *testing.T) {
+	doc, err := ParseJDR([]byte("[a@alice-10 b@alice-20]"))
+	assert.Nil(t, err)
+	ed1, _ := NewLinearEditor(doc, 1)
+	ed2, _ := NewLinearEditor(doc, 2)
+	p1, err := ed1.InsertAt(1, T0("x"))
+	assert.Nil(t, err)
+	p2, err := ed2.InsertAt(1, T0("y"))
+	assert.Nil(t, err)
+	m12, err := Merge(nil, [][]byte{doc, p1, p2})
+	assert.Nil(t, err)
+	m21, err := Merge(nil, [][]byte{doc, p2, p1})
+	assert.Nil(t, err)
+	assert.Equal(t, "[a x y b]", flatJDR(t, m12))
+	assert.Equal(t, m12, m21)
+}
+
+func TestLinearEditorPrepend(t *testing.T) {
+	for _, trains := range []bool{false, true} {
+		doc, err := ParseJDR([]byte("[0]"))
+		assert.Nil(t, err)
+		ed, err := NewLinearEditor(doc, 1)
+		assert.Nil(t, err)
+		ed.trains = trains
+		want := []string{"0"}
+		for i := 1; i <= 1000; i++ {
+			patch, err := ed.InsertAt(0, I0(Integer(i)), I0(-Integer(i)))
+			if !assert.Nil(t, err, i) {
+				break
+			}
+			doc, err = Merge(nil, [][]byte{doc, patch})
+			assert.Nil(t, err)
+			want = append([]string{strconv.Itoa(i), strconv.Itoa(-i)}, want...)
+		}
+		assert.Equal(t, "["+strings.Join(want, " ")+"]", flatJDR(t, doc))
+		assert.Equal(t, doc, []byte(ed.Doc()))
+	}
+}
+
+// TestLinearEditorRandom checks random edits against a plain slice
+func TestLinearEditorRandom(t *testing.T) {
+	for seed := int64(2); seed <= 3; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		doc, err := ParseJDR([]byte("[]"))
+		assert.Nil(t, err)
+		ed, err := NewLinearEditor(doc, 1)
+		assert.Nil(t, err)
+		ed.trains = seed == 3
+		var model []string
+		for i := 0; i < 3000; i++ {
+			var patch Stream
+			pos := rnd.Intn(len(model) + 1)
+			if len(model) > 0 && rnd.Intn(4) == 0 {
+				pos = min(pos, len(model)-1)
+				patch, err = ed.DeleteAt(pos)
+				model = append(model[:pos], model[pos+1:]...)
+			} else {
+				n := 1 + rnd.Intn(3)
+				var elems []Stream
+				var strs []string
+				for j := 0; j < n; j++ {
+					elems = append(elems, I0(Integer(i*10+j)))
+					strs = append(strs, strconv.Itoa(i*10+j))
+				}
+				patch, err = ed.InsertAt(pos, elems...)
+				model = append(model[:pos], append(strs, model[pos:]...)...)
+			}
+			if !assert.Nil(t, err, "seed %d step %d", seed, i) {
+				break
+			}
+			doc, err = Merge(nil, [][]byte{doc, patch})
+			assert.Nil(t, err)
+			it := NewIter(patch)
+			it.Read()
+			for in := it.Inner(); in.Read(); {
+				if in.ID().Seq>>6 == 0 {
+					t.Error("zero locator", string(RenderJDR(patch, StyleStamps)))
+				}
+			}
+		}
+		assert.Equal(t, "["+strings.Join(model, " ")+"]", flatJDR(t, doc))
+		assert.Equal(t, doc, []byte(ed.Doc()))
+		assert.Equal(t, len(model), ed.Len())
+	}
+}
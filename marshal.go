@@ -0,0 +1,719 @@
+package rdx
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// RDXMarshaler is implemented by types that render themselves into RDX.
+// The returned Stream must contain exactly one normalized element.
+type RDXMarshaler interface {
+	MarshalRDX() (Stream, error)
+}
+
+// RDXUnmarshaler is implemented by types that read themselves from RDX.
+// The Stream contains exactly one element.
+type RDXUnmarshaler interface {
+	UnmarshalRDX(rdx Stream) error
+}
+
+var (
+	ErrNotPointer      = errors.New("rdx: Unmarshal needs a non-nil pointer")
+	ErrUnsupportedType = errors.New("rdx: unsupported Go type")
+	ErrValueOverflow   = errors.New("rdx: value does not fit the Go type")
+	ErrCycle           = errors.New("rdx: Marshal met a cycle")
+	ErrUnexportedEmbed = errors.New("rdx: cannot set an embedded pointer to an unexported struct")
+)
+
+var (
+	idType          = reflect.TypeOf(ID{})
+	termType        = reflect.TypeOf(Term(nil))
+	streamType      = reflect.TypeOf(Stream(nil))
+	marshalerType   = reflect.TypeOf((*RDXMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*RDXUnmarshaler)(nil)).Elem()
+)
+
+// Marshal renders a Go value as an RDX element, in the spirit of
+// encoding/json. The default mapping is:
+//
+//	bool                 Term true/false
+//	int*, uint*          Integer
+//	float*               Float
+//	string               String
+//	Term                 Term
+//	ID                   Reference
+//	Stream               as is (must be one element)
+//	slice                Linear
+//	array                Tuple
+//	map                  Eulerian map of (key value) tuples
+//	struct               Tuple of the fields, in the order of declaration
+//	nil pointer, nil     ()
+//
+// The `rdx` field tag sets the Eulerian key name and options, e.g.
+// `rdx:"name,euler"`. Options `tuple`, `linear`, `euler` and `multix`
+// select the container type for the field (Multix elements get their
+// indexes plus one for Src, to be read back in that order); `stamp` marks an ID field that
+// carries the stamp of the enclosing element; `omitempty` skips zero values
+// in Eulerian structs; `rdx:"-"` skips the field. A blank field tagged like
+// `_ struct{} rdx:",euler"` sets the default container for the struct
+// itself. Embedded structs have their fields promoted; name conflicts
+// get resolved the way encoding/json does: the shallowest field wins,
+// then the tagged one, otherwise the fields are skipped. A cyclic value
+// is ErrCycle. The result is normalized.
+func Marshal(v any) (rdx Stream, err error) {
+	e := encodeState{seen: make(map[seenKey]struct{})}
+	rdx, err = e.marshalValue(nil, reflect.ValueOf(v), 0)
+	if err == nil {
+		rdx, err = Normalize(rdx)
+	}
+	return
+}
+
+// Unmarshal reads the first element of the stream into a Go value,
+// see Marshal for the mapping. Tombstones are skipped, () is the zero value.
+func Unmarshal(rdx Stream, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrNotPointer
+	}
+	it := NewIter(rdx)
+	if !it.Read() {
+		if it.HasFailed() {
+			return it.Error()
+		}
+		return ErrRecordNotFound
+	}
+	return unmarshalValue(&it, rv.Elem())
+}
+
+type rdxField struct {
+	name      string
+	index     []int
+	lit       byte
+	omitEmpty bool
+	tagged    bool
+}
+
+type rdxStruct struct {
+	fields []rdxField
+	byName map[string]int
+	stamp  []int
+	lit    byte
+}
+
+var rdxStructs sync.Map
+
+func parseRDXTag(tag string) (name string, lit byte, stamp, omitEmpty bool) {
+	opts := strings.Split(tag, ",")
+	name = opts[0]
+	for _, opt := range opts[1:] {
+		switch opt {
+		case "tuple":
+			lit = LitTuple
+		case "linear":
+			lit = LitLinear
+		case "euler":
+			lit = LitEuler
+		case "multix":
+			lit = LitMultix
+		case "stamp":
+			stamp = true
+		case "omitempty":
+			omitEmpty = true
+		}
+	}
+	return
+}
+
+func structOf(t reflect.Type) *rdxStruct {
+	if s, ok := rdxStructs.Load(t); ok {
+		return s.(*rdxStruct)
+	}
+	s := &rdxStruct{byName: make(map[string]int)}
+	s.collect(t, nil, map[reflect.Type]bool{t: true})
+	s.dominate()
+	for i, f := range s.fields {
+		s.byName[f.name] = i
+	}
+	rdxStructs.Store(t, s)
+	return s
+}
+
+func (s *rdxStruct) collect(t reflect.Type, index []int, path map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, tagged := f.Tag.Lookup("rdx")
+		if tag == "-" {
+			continue
+		}
+		name, lit, stamp, omitEmpty := parseRDXTag(tag)
+		if f.Name == "_" {
+			if index == nil {
+				s.lit = lit
+			}
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != idType {
+			if !path[ft] { // an embedding cycle otherwise
+				path[ft] = true
+				s.collect(ft, idx, path)
+				delete(path, ft)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if stamp && f.Type == idType {
+			s.stamp = idx
+			continue
+		}
+		named := tagged && name != ""
+		if !named {
+			name = f.Name
+		}
+		s.fields = append(s.fields, rdxField{
+			name:      name,
+			index:     idx,
+			lit:       lit,
+			omitEmpty: omitEmpty,
+			tagged:    named,
+		})
+	}
+}
+
+// dominate resolves the name conflicts of promoted fields, see Marshal
+func (s *rdxStruct) dominate() {
+	best := make(map[string][]int)
+	for i, f := range s.fields {
+		prev := best[f.name]
+		if len(prev) > 0 && len(s.fields[prev[0]].index) < len(f.index) {
+			continue
+		}
+		if len(prev) > 0 && len(s.fields[prev[0]].index) > len(f.index) {
+			prev = prev[:0]
+		}
+		best[f.name] = append(prev, i)
+	}
+	fields := s.fields[:0:0]
+	for i, f := range s.fields {
+		same := best[f.name]
+		win := len(same) == 1 && same[0] == i
+		if len(same) > 1 && f.tagged {
+			tagged := 0
+			for _, j := range same {
+				if s.fields[j].tagged {
+					tagged++
+				}
+			}
+			win = tagged == 1 && len(s.fields[same[0]].index) == len(f.index)
+		}
+		if win {
+			fields = append(fields, f)
+		}
+	}
+	s.fields = fields
+}
+
+// fieldOf walks the index path; false if an embedded pointer is nil
+func fieldOf(v reflect.Value, index []int) (reflect.Value, bool) {
+	for n, i := range index {
+		if n > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// fieldFor walks the index path, allocating nil embedded pointers
+func fieldFor(v reflect.Value, index []int) (reflect.Value, error) {
+	for n, i := range index {
+		if n > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, ErrUnexportedEmbed
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, nil
+}
+
+func appendKey(data []byte, key string) []byte {
+	if isJDRTerm(key) {
+		return WriteRDX(data, LitTerm, ID0, []byte(key))
+	}
+	return WriteRDX(data, LitString, ID0, []byte(key))
+}
+
+// encodeState keeps the pointers on the path from the root, to catch cycles
+type encodeState struct {
+	seen map[seenKey]struct{}
+}
+
+// seenKey includes the type, as a struct and its first field share the address
+type seenKey struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+func (e *encodeState) enter(key seenKey) error {
+	if _, ok := e.seen[key]; ok {
+		return ErrCycle
+	}
+	e.seen[key] = struct{}{}
+	return nil
+}
+
+func (e *encodeState) marshalValue(data []byte, v reflect.Value, lit byte) (rdx []byte, err error) {
+	if !v.IsValid() {
+		return append(data, RDXEmptyTuple...), nil
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) && (t.Kind() != reflect.Pointer || !v.IsNil()) {
+		var m Stream
+		m, err = v.Interface().(RDXMarshaler).MarshalRDX()
+		return append(data, m...), err
+	}
+	if v.CanAddr() && reflect.PointerTo(t).Implements(marshalerType) {
+		var m Stream
+		m, err = v.Addr().Interface().(RDXMarshaler).MarshalRDX()
+		return append(data, m...), err
+	}
+	switch t {
+	case idType:
+		return WriteRDX(data, LitReference, ID0, ZipID(v.Interface().(ID))), nil
+	case termType:
+		return WriteRDX(data, LitTerm, ID0, v.Bytes()), nil
+	case streamType:
+		return append(data, v.Bytes()...), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(data, RDXEmptyTuple...), nil
+		}
+		if v.Kind() == reflect.Pointer {
+			key := seenKey{v.Pointer(), 0, t}
+			if err = e.enter(key); err != nil {
+				return nil, err
+			}
+			defer delete(e.seen, key)
+		}
+		return e.marshalValue(data, v.Elem(), lit)
+	case reflect.Bool:
+		if v.Bool() {
+			return WriteRDX(data, LitTerm, ID0, []byte("true")), nil
+		}
+		return WriteRDX(data, LitTerm, ID0, []byte("false")), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return WriteRDX(data, LitInteger, ID0, ZipInt64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return nil, ErrValueOverflow
+		}
+		return WriteRDX(data, LitInteger, ID0, ZipInt64(int64(u))), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) {
+			return nil, ErrBadFloatRecord
+		}
+		return WriteRDX(data, LitFloat, ID0, ZipFloat64(f)), nil
+	case reflect.String:
+		return WriteRDX(data, LitString, ID0, []byte(v.String())), nil
+	case reflect.Slice, reflect.Array:
+		if lit == 0 {
+			lit = LitLinear
+			if v.Kind() == reflect.Array {
+				lit = LitTuple
+			}
+		}
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			key := seenKey{v.Pointer(), v.Len(), t}
+			if err = e.enter(key); err != nil {
+				return nil, err
+			}
+			defer delete(e.seen, key)
+		}
+		vals := make([]Stream, 0, v.Len())
+		for i := 0; i < v.Len() && err == nil; i++ {
+			var el []byte
+			el, err = e.marshalValue(nil, v.Index(i), 0)
+			if err == nil && lit == LitMultix {
+				// Multix keys its elements by Src
+				l, _, val, _, _ := ReadRDX(el)
+				el = WriteRDX(el[:0:0], l, ID{Src: uint64(i + 1)}, val)
+			}
+			vals = append(vals, el)
+		}
+		if err != nil {
+			return
+		}
+		return append(data, MakePLEXOf(lit, ID0, vals, nil)...), nil
+	case reflect.Map:
+		if !v.IsNil() {
+			key := seenKey{v.Pointer(), 0, t}
+			if err = e.enter(key); err != nil {
+				return nil, err
+			}
+			defer delete(e.seen, key)
+		}
+		vals := make([]Stream, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() && err == nil {
+			var kv []byte
+			if iter.Key().Kind() == reflect.String {
+				kv = appendKey(kv, iter.Key().String())
+			} else {
+				kv, err = e.marshalValue(kv, iter.Key(), 0)
+			}
+			if err == nil {
+				kv, err = e.marshalValue(kv, iter.Value(), 0)
+			}
+			vals = append(vals, MakeTuple(ID0, kv))
+		}
+		if err != nil {
+			return
+		}
+		return append(data, MakePLEXOf(LitEuler, ID0, vals, nil)...), nil
+	case reflect.Struct:
+		return e.marshalStruct(data, v, lit)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+func (e *encodeState) marshalStruct(data []byte, v reflect.Value, lit byte) (rdx []byte, err error) {
+	s := structOf(v.Type())
+	if lit == 0 {
+		lit = s.lit
+	}
+	if lit == 0 {
+		lit = LitTuple
+	}
+	id := ID0
+	if s.stamp != nil {
+		if f, ok := fieldOf(v, s.stamp); ok {
+			id = f.Interface().(ID)
+		}
+	}
+	vals := make([]Stream, 0, len(s.fields))
+	for _, f := range s.fields {
+		fv, ok := fieldOf(v, f.index)
+		if lit == LitEuler && (!ok || (f.omitEmpty && fv.IsZero())) {
+			continue
+		}
+		var el []byte
+		if ok {
+			el, err = e.marshalValue(nil, fv, f.lit)
+		} else {
+			el = append(el, RDXEmptyTuple...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if lit == LitEuler {
+			el = MakeTuple(ID0, append(appendKey(nil, f.name), el...))
+		}
+		vals = append(vals, el)
+	}
+	return append(data, MakePLEXOf(lit, id, vals, nil)...), nil
+}
+
+func isEmptyTuple(it *Iter) bool {
+	return it.Lit() == LitTuple && len(it.Value()) == 0
+}
+
+func unmarshalValue(it *Iter, v reflect.Value) (err error) {
+	if v.CanAddr() && reflect.PointerTo(v.Type()).Implements(unmarshalerType) {
+		return v.Addr().Interface().(RDXUnmarshaler).UnmarshalRDX(it.Record())
+	}
+	if v.Kind() == reflect.Pointer && v.Type().Implements(unmarshalerType) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface().(RDXUnmarshaler).UnmarshalRDX(it.Record())
+	}
+	if isEmptyTuple(it) {
+		v.SetZero()
+		return nil
+	}
+	switch v.Type() {
+	case idType:
+		if it.Lit() != LitReference {
+			return ErrWrongRDXRecordType
+		}
+		v.Set(reflect.ValueOf(it.Reference()))
+		return nil
+	case termType:
+		if it.Lit() != LitTerm && it.Lit() != LitString {
+			return ErrWrongRDXRecordType
+		}
+		v.SetBytes(append(Term(nil), it.Value()...))
+		return nil
+	case streamType:
+		v.SetBytes(append(Stream(nil), it.Record()...))
+		return nil
+	}
+	kind := v.Kind()
+	if it.Lit() == LitTuple && kind != reflect.Pointer && kind != reflect.Interface &&
+		kind != reflect.Slice && kind != reflect.Array && kind != reflect.Struct {
+		in := it.Inner() // an envelope
+		if !in.Read() || in.HasMore() {
+			return ErrWrongRDXRecordType
+		}
+		return unmarshalValue(&in, v)
+	}
+	switch kind {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalValue(it, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return ErrUnsupportedType
+		}
+		var g any
+		g, err = genericOf(it)
+		if err == nil {
+			if g == nil {
+				v.SetZero()
+			} else {
+				v.Set(reflect.ValueOf(g))
+			}
+		}
+		return
+	case reflect.Bool:
+		if it.Lit() != LitTerm {
+			return ErrWrongRDXRecordType
+		}
+		switch string(it.Value()) {
+		case "true":
+			v.SetBool(true)
+		case "false":
+			v.SetBool(false)
+		default:
+			return ErrWrongRDXRecordType
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if it.Lit() != LitInteger {
+			return ErrWrongRDXRecordType
+		}
+		i := int64(it.Integer())
+		if v.OverflowInt(i) {
+			return ErrValueOverflow
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if it.Lit() != LitInteger {
+			return ErrWrongRDXRecordType
+		}
+		i := int64(it.Integer())
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return ErrValueOverflow
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		switch it.Lit() {
+		case LitFloat:
+			v.SetFloat(float64(it.Float()))
+		case LitInteger:
+			v.SetFloat(float64(it.Integer()))
+		default:
+			return ErrWrongRDXRecordType
+		}
+	case reflect.String:
+		if it.Lit() != LitString && it.Lit() != LitTerm {
+			return ErrWrongRDXRecordType
+		}
+		v.SetString(string(it.Value()))
+	case reflect.Slice:
+		if !IsPLEX(it.Lit()) {
+			return ErrWrongRDXRecordType
+		}
+		s := reflect.MakeSlice(v.Type(), 0, 0)
+		in := it.Inner()
+		for in.Read() && err == nil {
+			if !in.IsLive() {
+				continue
+			}
+			el := reflect.New(v.Type().Elem()).Elem()
+			err = unmarshalValue(&in, el)
+			s = reflect.Append(s, el)
+		}
+		if err == nil {
+			v.Set(s)
+		}
+	case reflect.Array:
+		if !IsPLEX(it.Lit()) {
+			return ErrWrongRDXRecordType
+		}
+		v.SetZero()
+		in := it.Inner()
+		for i := 0; i < v.Len() && in.Read() && err == nil; i++ {
+			if in.IsLive() {
+				err = unmarshalValue(&in, v.Index(i))
+			}
+		}
+	case reflect.Map:
+		if it.Lit() != LitEuler {
+			return ErrWrongRDXRecordType
+		}
+		m := reflect.MakeMap(v.Type())
+		in := it.Inner()
+		for in.Read() && err == nil {
+			if !in.IsLive() || in.Lit() != LitTuple {
+				continue
+			}
+			kv := in.Inner()
+			if !kv.Read() {
+				continue
+			}
+			key := reflect.New(v.Type().Key()).Elem()
+			val := reflect.New(v.Type().Elem()).Elem()
+			err = unmarshalValue(&kv, key)
+			if err == nil && kv.Read() {
+				if !kv.IsLive() {
+					continue
+				}
+				err = unmarshalValue(&kv, val)
+			}
+			m.SetMapIndex(key, val)
+		}
+		if err == nil {
+			v.Set(m)
+		}
+	case reflect.Struct:
+		err = unmarshalStruct(it, v)
+	default:
+		err = ErrUnsupportedType
+	}
+	if err == nil && it.HasFailed() {
+		err = it.Error()
+	}
+	return
+}
+
+func unmarshalStruct(it *Iter, v reflect.Value) (err error) {
+	if !IsPLEX(it.Lit()) {
+		return ErrWrongRDXRecordType
+	}
+	s := structOf(v.Type())
+	if s.stamp != nil {
+		f, err := fieldFor(v, s.stamp)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(it.ID()))
+	}
+	in := it.Inner()
+	if it.Lit() == LitEuler {
+		for in.Read() && err == nil {
+			if !in.IsLive() || in.Lit() != LitTuple {
+				continue
+			}
+			kv := in.Inner()
+			if !kv.Read() || (kv.Lit() != LitTerm && kv.Lit() != LitString) {
+				continue
+			}
+			n, ok := s.byName[string(kv.Value())]
+			if !ok || !kv.Read() || !kv.IsLive() {
+				continue
+			}
+			var f reflect.Value
+			if f, err = fieldFor(v, s.fields[n].index); err == nil {
+				err = unmarshalValue(&kv, f)
+			}
+		}
+		return
+	}
+	for n := 0; n < len(s.fields) && in.Read() && err == nil; n++ {
+		if !in.IsLive() {
+			continue
+		}
+		var f reflect.Value
+		if f, err = fieldFor(v, s.fields[n].index); err == nil {
+			err = unmarshalValue(&in, f)
+		}
+	}
+	return
+}
+
+// genericOf converts an element into a generic Go value: int64, float64,
+// string, bool, nil, ID, []any or map[string]any.
+func genericOf(it *Iter) (g any, err error) {
+	switch it.Lit() {
+	case LitFloat:
+		return float64(it.Float()), nil
+	case LitInteger:
+		return int64(it.Integer()), nil
+	case LitReference:
+		return it.Reference(), nil
+	case LitString:
+		return string(it.Value()), nil
+	case LitTerm:
+		switch string(it.Value()) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return string(it.Value()), nil
+		}
+	case LitEuler:
+		if isJSONObject(it, true) {
+			m := make(map[string]any)
+			in := it.Inner()
+			for in.Read() && err == nil {
+				if !in.IsLive() {
+					continue
+				}
+				kv := in.Inner()
+				kv.Read()
+				key := string(kv.Value())
+				if kv.Read() && kv.IsLive() {
+					m[key], err = genericOf(&kv)
+				}
+			}
+			return m, err
+		}
+		fallthrough
+	case LitTuple, LitLinear, LitMultix:
+		if isEmptyTuple(it) {
+			return nil, nil
+		}
+		l := []any{}
+		in := it.Inner()
+		for in.Read() && err == nil {
+			if !in.IsLive() {
+				continue
+			}
+			var el any
+			el, err = genericOf(&in)
+			l = append(l, el)
+		}
+		return l, err
+	default:
+		return nil, ErrBadRecord
+	}
+}
@@ -0,0 +1,232 @@
+package rdx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAddress struct {
+	City string `rdx:"city"`
+	Zip  int    `rdx:"zip,omitempty"`
+}
+
+type testBase struct {
+	Stamp ID `rdx:",stamp"`
+	Kind  string
+}
+
+type testPerson struct {
+	_ struct{} `rdx:",euler"`
+	testBase
+	Name    string         `rdx:"name"`
+	Age     uint8          `rdx:"age"`
+	Tags    []string       `rdx:"tags,euler"`
+	Scores  map[string]int `rdx:"scores"`
+	Home    *testAddress   `rdx:"home,euler"`
+	Friend  ID             `rdx:"friend"`
+	Coords  [2]float64     `rdx:"coords"`
+	secret  int
+	Skipped int `rdx:"-"`
+}
+
+type testUpper string
+
+func (u testUpper) MarshalRDX() (Stream, error) {
+	return S0(strings.ToUpper(string(u))), nil
+}
+
+func (u *testUpper) UnmarshalRDX(rdx Stream) error {
+	it := NewIter(rdx)
+	it.Read()
+	*u = testUpper(strings.ToLower(string(it.Value())))
+	return nil
+}
+
+func TestMarshalStruct(t *testing.T) {
+	p := testPerson{
+		testBase: testBase{Stamp: ID{Src: 0xb0b, Seq: 4 << 6}, Kind: "human"},
+		Name:     "Alice",
+		Age:      33,
+		Tags:     []string{"b", "a"},
+		Scores:   map[string]int{"x": 1, "long key": 2},
+		Home:     &testAddress{City: "Paris"},
+		Friend:   ID{Src: 0xb0b, Seq: 1 << 6},
+		Coords:   [2]float64{1.5, -2},
+		secret:   1,
+		Skipped:  2,
+	}
+	rdx, err := Marshal(p)
+	assert.Nil(t, err)
+	assert.Equal(t,
+		"{@hB-40 (Kind \"human\") (age 33) (coords (1.5e+00 -2e+00)) (friend hB-10)"+
+			" (home {(city \"Paris\")}) (name \"Alice\")"+
+			" (scores {(\"long key\" 2) (x 1)}) (tags {\"a\" \"b\"})}",
+		string(RenderJDR(rdx, StyleStamps)))
+
+	var q testPerson
+	assert.Nil(t, Unmarshal(rdx, &q))
+	p.secret, p.Skipped = 0, 0
+	p.Tags = []string{"a", "b"}
+	assert.Equal(t, p, q)
+}
+
+func TestMarshalDefaults(t *testing.T) {
+	type pair struct {
+		Key string
+		Val *int
+	}
+	rdx, err := Marshal([]pair{{"a", nil}, {"b", new(int)}})
+	assert.Nil(t, err)
+	assert.Equal(t, "[(\"a\" ()) (\"b\" 0)]", string(RenderJDR(rdx, 0)))
+	var back []pair
+	assert.Nil(t, Unmarshal(rdx, &back))
+	assert.Equal(t, 2, len(back))
+	assert.Nil(t, back[0].Val)
+	assert.Equal(t, 0, *back[1].Val)
+
+	type multi struct {
+		X []int    `rdx:",multix"`
+		Y [2]*pair `rdx:",multix"`
+	}
+	rdx, err = Marshal(multi{X: []int{3, 1, 2}, Y: [2]*pair{{"a", nil}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "(<3@1-0 1@2-0 2@3-0> <(@1-0 \"a\" ()) (@2-0 )>)", string(RenderJDR(rdx, StyleStamps)))
+	var mb multi
+	assert.Nil(t, Unmarshal(rdx, &mb))
+	assert.Equal(t, []int{3, 1, 2}, mb.X)
+	assert.Equal(t, "a", mb.Y[0].Key)
+	assert.Nil(t, mb.Y[1])
+
+	rdx, err = Marshal(map[int]bool{2: true, 1: false})
+	assert.Nil(t, err)
+	assert.Equal(t, "{(1 false) (2 true)}", string(RenderJDR(rdx, 0)))
+
+	rdx, err = Marshal([]testUpper{"abc"})
+	assert.Nil(t, err)
+	assert.Equal(t, "[\"ABC\"]", string(RenderJDR(rdx, 0)))
+	var up []testUpper
+	assert.Nil(t, Unmarshal(rdx, &up))
+	assert.Equal(t, []testUpper{"abc"}, up)
+
+	_, err = Marshal(uint64(1) << 63)
+	assert.Equal(t, ErrValueOverflow, err)
+	_, err = Marshal(make(chan int))
+	assert.Equal(t, ErrUnsupportedType, err)
+}
+
+func TestUnmarshalJDR(t *testing.T) {
+	rdx, err := ParseNormalizeJDR([]byte(
+		"{name:\"Bob\", age:(40), tags:[x, y], scores:{x:1, y:2@3}, extra:1}"))
+	assert.Nil(t, err)
+	var p testPerson
+	assert.Nil(t, Unmarshal(rdx, &p))
+	assert.Equal(t, "Bob", p.Name)
+	assert.Equal(t, uint8(40), p.Age)
+	assert.Equal(t, []string{"x", "y"}, p.Tags)
+	assert.Equal(t, map[string]int{"x": 1}, p.Scores)
+
+	var g any
+	assert.Nil(t, Unmarshal(rdx, &g))
+	assert.Equal(t, map[string]any{
+		"name":   "Bob",
+		"age":    []any{int64(40)},
+		"tags":   []any{"x", "y"},
+		"scores": map[string]any{"x": int64(1)},
+		"extra":  int64(1),
+	}, g)
+
+	var small int8
+	rdx, _ = ParseJDR([]byte("300"))
+	assert.Equal(t, ErrValueOverflow, Unmarshal(rdx, &small))
+	assert.Equal(t, ErrWrongRDXRecordType, Unmarshal(rdx, &p))
+	assert.Equal(t, ErrNotPointer, Unmarshal(rdx, p))
+}
+
+type testInner struct {
+	X int64
+}
+
+type testOuter struct {
+	*testInner
+	Y int64
+}
+
+type testNode struct {
+	Name string
+	Next *testNode
+}
+
+type testLoop struct {
+	*testLoop
+	Z int
+}
+
+func TestMarshalEmbedding(t *testing.T) {
+	// no way to allocate an unexported embedded pointer
+	rdx, err := ParseNormalizeJDR([]byte("(1 2)"))
+	assert.Nil(t, err)
+	var o testOuter
+	assert.Equal(t, ErrUnexportedEmbed, Unmarshal(rdx, &o))
+	o.testInner = &testInner{}
+	assert.Nil(t, Unmarshal(rdx, &o))
+	assert.Equal(t, testOuter{&testInner{1}, 2}, o)
+
+	// name conflicts: the shallowest field wins, then the tagged one
+	type a struct{ X, Y, Z int }
+	type b struct {
+		X int
+		Y int `rdx:"Y"`
+		Z int
+	}
+	type c struct {
+		_ struct{} `rdx:",euler"`
+		a
+		b
+		X int
+	}
+	rdx, err = Marshal(c{a: a{1, 2, 3}, b: b{4, 5, 6}, X: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, "{(X 7) (Y 5)}", string(RenderJDR(rdx, 0)))
+	var back c
+	assert.Nil(t, Unmarshal(rdx, &back))
+	assert.Equal(t, c{b: b{Y: 5}, X: 7}, back)
+
+	// cycles
+	n := &testNode{Name: "a"}
+	rdx, err = Marshal(n)
+	assert.Nil(t, err)
+	assert.Equal(t, "(\"a\" ())", string(RenderJDR(rdx, 0)))
+	n.Next = &testNode{Name: "b", Next: n}
+	_, err = Marshal(n)
+	assert.Equal(t, ErrCycle, err)
+	m := map[string]any{}
+	m["m"] = m
+	_, err = Marshal(m)
+	assert.Equal(t, ErrCycle, err)
+	s := []any{nil}
+	s[0] = s
+	_, err = Marshal(s)
+	assert.Equal(t, ErrCycle, err)
+	// a shared value is not a cycle
+	shared := &testInner{5}
+	rdx, err = Marshal([]*testInner{shared, shared})
+	assert.Nil(t, err)
+	assert.Equal(t, "[(5) (5)]", string(RenderJDR(rdx, 0)))
+	// a struct and its first field share the address
+	type zin struct{ V int }
+	type zout struct {
+		X zin
+		P *zin
+	}
+	z := &zout{X: zin{3}}
+	z.P = &z.X
+	rdx, err = Marshal(z)
+	assert.Nil(t, err)
+	assert.Equal(t, "((3) (3))", string(RenderJDR(rdx, 0)))
+
+	rdx, err = Marshal(testLoop{Z: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "(1)", string(RenderJDR(rdx, 0)))
+}
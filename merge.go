@@ -0,0 +1,193 @@
+package rdx
+
+import "io"
+
+// MergeReader merges sorted Readers (files, decoders, Iters...) into one
+// sorted Reader, record by record. Records at the same spot, i.e. equal
+// by the Compare, get merged with MergeSameSpotElements. A record of an
+// input is only used before the next Read of that input, so any Reader
+// reusing its buffer works. More than MaxInputs inputs get merged in a
+// tree of MergeReaders. An input error stops the merge, see Error.
+type MergeReader struct {
+	heap   []mergeInput
+	inputs []Reader
+	z      Compare
+	eqs    []int
+	same   Heap
+	out    Stream
+	err    error
+}
+
+type mergeInput struct {
+	r  Reader
+	it Iter
+}
+
+// NewMergeReader makes a merging reader; z orders the top-level records
+// of the inputs, e.g. CompareID for ID-sorted logs. CompareTuple merges
+// the inputs record by record, like Merge does.
+func NewMergeReader[R Reader](z Compare, inputs []R) *MergeReader {
+	readers := make([]Reader, 0, len(inputs))
+	for _, r := range inputs {
+		readers = append(readers, r)
+	}
+	for len(readers) > MaxInputs {
+		level := make([]Reader, 0, (len(readers)+MaxInputs-1)/MaxInputs)
+		for i := 0; i < len(readers); i += MaxInputs {
+			group := readers[i:min(i+MaxInputs, len(readers))]
+			level = append(level, &MergeReader{inputs: group, z: z})
+		}
+		readers = level
+	}
+	return &MergeReader{inputs: readers, z: z}
+}
+
+// MergeTo merges the inputs into the writer, see MergeReader. The output
+// goes out in chunks as it is produced, so the memory use does not depend
+// on the size of the inputs.
+func MergeTo[R Reader](w io.Writer, z Compare, inputs []R) error {
+	const chunk = 1 << 16
+	m := NewMergeReader(z, inputs)
+	buf := make([]byte, 0, chunk)
+	for m.Read() {
+		buf = append(buf, m.Record()...)
+		if len(buf) >= chunk {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	if m.Error() != nil {
+		return m.Error()
+	}
+	if len(buf) > 0 {
+		_, err := w.Write(buf)
+		return err
+	}
+	return nil
+}
+
+func (m *MergeReader) less(i, j int) bool {
+	return m.z(&m.heap[i].it, &m.heap[j].it) < Eq
+}
+
+func (m *MergeReader) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !m.less(i, p) {
+			break
+		}
+		m.heap[i], m.heap[p] = m.heap[p], m.heap[i]
+		i = p
+	}
+}
+
+func (m *MergeReader) down(i int) {
+	for {
+		j := 2*i + 1
+		if j >= len(m.heap) {
+			break
+		}
+		if j+1 < len(m.heap) && m.less(j+1, j) {
+			j++
+		}
+		if !m.less(j, i) {
+			break
+		}
+		m.heap[i], m.heap[j] = m.heap[j], m.heap[i]
+		i = j
+	}
+}
+
+// next reads the next record of an input into in; false on the end
+// or an error
+func (m *MergeReader) next(in *mergeInput) bool {
+	if !in.r.Read() {
+		if err := in.r.Error(); err != nil {
+			m.err = err
+		}
+		return false
+	}
+	in.it = NewIter(in.r.Record())
+	if !in.it.Read() {
+		m.err = in.it.Error()
+		if m.err == nil {
+			m.err = ErrBadRecord
+		}
+		return false
+	}
+	return true
+}
+
+func (m *MergeReader) Read() bool {
+	if m.inputs != nil {
+		m.heap = make([]mergeInput, 0, len(m.inputs))
+		for _, r := range m.inputs {
+			in := mergeInput{r: r}
+			if m.next(&in) {
+				m.heap = append(m.heap, in)
+				m.up(len(m.heap) - 1)
+			}
+		}
+		m.inputs = nil
+	}
+	m.out = m.out[:0]
+	if m.err != nil || len(m.heap) == 0 {
+		return false
+	}
+	// the records equal to the top make a subtree at the root
+	m.eqs = append(m.eqs[:0], 0)
+	for i := 0; i < len(m.eqs); i++ {
+		for c := 2*m.eqs[i] + 1; c <= 2*m.eqs[i]+2 && c < len(m.heap); c++ {
+			if m.z(&m.heap[0].it, &m.heap[c].it) == Eq {
+				m.eqs = append(m.eqs, c)
+			}
+		}
+	}
+	if len(m.eqs) == 1 {
+		m.out = append(m.out, m.heap[0].it.Record()...)
+	} else {
+		m.same = m.same[:0]
+		for _, k := range m.eqs {
+			m.same = append(m.same, m.heap[k].it)
+		}
+		m.out, m.err = MergeSameSpotElements(m.out, m.same)
+		if m.err != nil {
+			return false
+		}
+	}
+	// advance the inputs bottom-up (the list is breadth-first, so sorted),
+	// hence every subtree is a heap again by the time its root sifts down
+	for i := len(m.eqs) - 1; i >= 0; i-- {
+		k := m.eqs[i]
+		if !m.next(&m.heap[k]) {
+			if m.err != nil {
+				return true
+			}
+			last := len(m.heap) - 1
+			m.heap[k] = m.heap[last]
+			m.heap = m.heap[:last]
+		}
+		if k < len(m.heap) {
+			m.down(k)
+		}
+	}
+	return true
+}
+
+// Record returns the merged record; it is valid till the next Read.
+func (m *MergeReader) Record() Stream {
+	return m.out
+}
+
+func (m *MergeReader) Parsed() (lit byte, id ID, value []byte) {
+	it := NewIter(m.out)
+	it.Read()
+	return it.Parsed()
+}
+
+// Error returns the first error of any input or of the merge itself.
+func (m *MergeReader) Error() error {
+	return m.err
+}
@@ -0,0 +1,105 @@
+package rdx
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeReader(t *testing.T) {
+	// 200 sorted sets of integers: input i has i, i+7, i+14...
+	var inputs []*Iter
+	var all [][]byte
+	want := map[int]bool{}
+	for i := 0; i < 200; i++ {
+		var rdx Stream
+		for n := i; n < 1000; n += 7 + i%5 {
+			rdx = rdx.AppendInteger(int64(n))
+			want[n] = true
+		}
+		it := NewIter(rdx)
+		inputs = append(inputs, &it)
+		all = append(all, rdx)
+	}
+	m := NewMergeReader(CompareEuler, inputs)
+	var got []int
+	for m.Read() {
+		lit, _, val := m.Parsed()
+		assert.Equal(t, byte(LitInteger), lit)
+		got = append(got, int(UnzipInt64(val)))
+	}
+	assert.Nil(t, m.Error())
+	assert.Equal(t, len(want), len(got))
+	for i := 1; i < len(got); i++ {
+		assert.Less(t, got[i-1], got[i])
+	}
+
+	// same as HeapMerge for up to MaxInputs
+	var merged bytes.Buffer
+	readers := []*Iter{}
+	for _, rdx := range all[:MaxInputs] {
+		it := NewIter(rdx)
+		readers = append(readers, &it)
+	}
+	assert.Nil(t, MergeTo(&merged, CompareEuler, readers))
+	correct, err := HeapMerge(nil, all[:MaxInputs], CompareEuler)
+	assert.Nil(t, err)
+	assert.Equal(t, correct, merged.Bytes())
+}
+
+func TestMergeReaderJDR(t *testing.T) {
+	logs := []string{
+		`{@alice-2 a:1} 1@alice-4`,
+		`{@alice-2 b:2}`,
+		`{@alice-2 a:3@bob-8} 2@bob-8 x`,
+	}
+	var decs []*JDRDecoder
+	var parsed [][]byte
+	for _, log := range logs {
+		decs = append(decs, NewJDRDecoder(strings.NewReader(log)))
+		rdx, err := ParseJDR([]byte(log))
+		assert.Nil(t, err)
+		parsed = append(parsed, rdx)
+	}
+	var out bytes.Buffer
+	assert.Nil(t, MergeTo(&out, CompareTuple, decs))
+	correct, err := Merge(nil, parsed)
+	assert.Nil(t, err)
+	assert.Equal(t, string(RenderJDR(correct, StyleStamps)),
+		string(RenderJDR(out.Bytes(), StyleStamps)))
+
+	// an error in any input stops the merge
+	decs = decs[:0]
+	for i := 0; i < 100; i++ {
+		log := strconv.Itoa(i)
+		if i == 77 {
+			log = "{1 2]"
+		}
+		decs = append(decs, NewJDRDecoder(strings.NewReader(log)))
+	}
+	out.Reset()
+	err = MergeTo(&out, CompareTuple, decs)
+	assert.ErrorIs(t, err, ErrBadJDRNesting)
+	assert.Equal(t, 0, out.Len())
+}
+
+func TestHeap2(t *testing.T) {
+	less := func(a, b Iter) bool {
+		return CompareEuler(&a, &b) < Eq
+	}
+	pick := func(inputs []Iter, pre Stream) Stream {
+		return append(pre, inputs[0].Record()...)
+	}
+	a := Stream(nil).AppendInteger(1).AppendInteger(3)
+	b := Stream(nil).AppendInteger(2).AppendInteger(3)
+	heap := MakeHeap2([]Iter{NewIter(a), NewIter(b)}, pick, less)
+	assert.Nil(t, heap.ReadAll())
+	assert.Equal(t, "1 2 3", string(RenderJDR(heap.Record(), 0)))
+
+	bad := append(Stream(nil).AppendInteger(1), 'i', 9, 0)
+	heap = MakeHeap2([]Iter{NewIter(a), NewIter(bad)}, pick, less)
+	assert.Equal(t, ErrIncomplete, heap.ReadAll())
+}
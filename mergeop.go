@@ -0,0 +1,157 @@
+package rdx
+
+import "io"
+
+// MergeOperator plugs the RDX merge into an LSM database (RocksDB, Pebble
+// and the like) as its merge operator, turning it into a CRDT store.
+// Values and operands are normalized RDX streams, a document and its
+// patches. As the merge is commutative, associative and idempotent, any
+// order and grouping of operands converges to the same value.
+//
+// FullMerge and PartialMerge have the shape of a RocksDB operator,
+// NewValueMerger and ValueMerger have the shape of a Pebble one. Neither
+// is imported, so wrap those with a closure:
+//
+//	pebble.Merger{
+//		Name: op.Name(),
+//		Merge: func(key, value []byte) (pebble.ValueMerger, error) {
+//			return op.NewValueMerger(key, value)
+//		},
+//	}
+//
+// Deleted elements are tombstones, i.e. records of odd revisions; these
+// are deletion markers to merge like any other operand, so they beat the
+// older values wherever those are. A database-level delete of a key
+// cuts off the history, so a missing existing value is an empty one.
+type MergeOperator struct{}
+
+func (op MergeOperator) Name() string {
+	return "rdx.MergeOperator"
+}
+
+// FullMerge merges the operands, oldest first, into the existing value,
+// nil if none. That is the whole history of the key, so the tombstones
+// get compacted, see ValueMerger.Finish. The result is false on bad data.
+func (op MergeOperator) FullMerge(key, existing []byte, operands [][]byte) ([]byte, bool) {
+	inputs := make([][]byte, 0, len(operands)+1)
+	inputs = append(inputs, existing)
+	inputs = append(inputs, operands...)
+	merged, err := Merge(nil, inputs)
+	if err != nil {
+		return nil, false
+	}
+	compact, err := compactTombstones(make([]byte, 0, len(merged)), merged, LitTuple, false, coversAll)
+	if err != nil {
+		return nil, false
+	}
+	return compact, true
+}
+
+// PartialMerge merges two operands into one; the tombstones stay as the
+// older values they override may be anywhere below.
+func (op MergeOperator) PartialMerge(key, left, right []byte) ([]byte, bool) {
+	merged, err := Merge(nil, [][]byte{left, right})
+	if err != nil {
+		return nil, false
+	}
+	return merged, true
+}
+
+// NewValueMerger starts a merge of the operands of a key with its newest
+// (or oldest) one.
+func (op MergeOperator) NewValueMerger(key, value []byte) (*ValueMerger, error) {
+	vm := &ValueMerger{}
+	if err := vm.MergeNewer(value); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// ValueMerger merges the operands of a key one by one, Pebble style.
+// The operands are merged right away, so the caller keeps those.
+type ValueMerger struct {
+	value  []byte
+	buf    []byte
+	inputs [2][]byte
+}
+
+func (vm *ValueMerger) merge(value []byte) (err error) {
+	vm.inputs[0], vm.inputs[1] = vm.value, value
+	vm.buf, err = Merge(vm.buf[:0], vm.inputs[:])
+	if err == nil {
+		vm.value, vm.buf = vm.buf, vm.value
+	}
+	vm.inputs[0], vm.inputs[1] = nil, nil
+	return
+}
+
+// MergeNewer merges an operand newer than the ones merged so far;
+// the order makes no difference for RDX.
+func (vm *ValueMerger) MergeNewer(value []byte) error {
+	return vm.merge(value)
+}
+
+// MergeOlder merges an operand older than the ones merged so far.
+func (vm *ValueMerger) MergeOlder(value []byte) error {
+	return vm.merge(value)
+}
+
+// Finish returns the merged value. If the base value is included, there
+// is nothing older to override, so the tombstones get compacted: the
+// value of a deleted FIRST element goes unless it is the key that puts
+// the element in its place (in an Euler set or a map entry) or a Term,
+// as an empty Term has no JDR form. The ID of
+// a tombstone stays to beat concurrent writes yet to come, and so does
+// the content of a deleted PLEX container to come back on its revival.
+func (vm *ValueMerger) Finish(includesBase bool) ([]byte, io.Closer, error) {
+	if !includesBase {
+		return vm.value, nil, nil
+	}
+	compact, err := compactTombstones(vm.buf[:0], vm.value, LitTuple, false, coversAll)
+	if err != nil {
+		return nil, nil, err
+	}
+	vm.value, vm.buf = compact, vm.value
+	return vm.value, nil, nil
+}
+
+func coversAll(id ID) bool {
+	return true
+}
+
+// compactTombstones appends the data with the values of deleted FIRST
+// elements (but Terms) cut off, if covered by the predicate; plit is the
+// container, keyed is for map entries. Deleted PLEX containers keep their
+// contents, as a replica that did not compact would bring those back on a
+// revival; otherwise, the replicas would diverge.
+func compactTombstones(to, data []byte, plit byte, keyed bool, covered func(ID) bool) (ret []byte, err error) {
+	ret = to
+	it := NewIter(data)
+	for n := 0; it.Read(); n++ {
+		lit := it.Lit()
+		switch {
+		case IsPLEX(lit):
+			stack := make(Marks, 0, 1)
+			ret = OpenTLV(ret, lit, &stack)
+			key := ZipID(it.ID())
+			ret = append(ret, byte(len(key)))
+			ret = append(ret, key...)
+			ret, err = compactTombstones(ret, it.Value(), lit, lit == LitTuple && plit == LitEuler, covered)
+			if err != nil {
+				return nil, err
+			}
+			ret, err = CloseTLV(ret, lit, &stack)
+		case !it.IsLive() && lit != LitTerm && plit != LitEuler && !(keyed && n == 0) && covered(it.ID()):
+			ret = WriteRDX(ret, lit, it.ID(), nil)
+		default:
+			ret = append(ret, it.Record()...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if it.HasFailed() {
+		return nil, it.Error()
+	}
+	return
+}
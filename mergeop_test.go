@@ -0,0 +1,182 @@
+package rdx
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lsmStore is a toy LSM: every write is a run of its own, compactions
+// merge adjacent runs; runs[0] is the oldest, the bottom of the tree.
+type lsmStore struct {
+	op   MergeOperator
+	runs []map[string][]byte
+}
+
+func (db *lsmStore) Merge(key string, operand []byte) {
+	db.runs = append(db.runs, map[string][]byte{key: operand})
+}
+
+// Compact merges the runs [i, j); a bottom compaction includes the base
+func (db *lsmStore) Compact(t *testing.T, i, j int) {
+	merged := map[string][]byte{}
+	for _, run := range db.runs[i:j] {
+		for key, value := range run {
+			if prev, ok := merged[key]; ok {
+				res, ok := db.op.PartialMerge([]byte(key), prev, value)
+				assert.True(t, ok)
+				value = res
+			}
+			merged[key] = value
+		}
+	}
+	if i == 0 {
+		for key, value := range merged {
+			vm, err := db.op.NewValueMerger([]byte(key), value)
+			assert.Nil(t, err)
+			res, closer, err := vm.Finish(true)
+			assert.Nil(t, err)
+			assert.Nil(t, closer)
+			merged[key] = res
+		}
+	}
+	runs := append([]map[string][]byte{}, db.runs[:i]...)
+	runs = append(runs, merged)
+	db.runs = append(runs, db.runs[j:]...)
+}
+
+func (db *lsmStore) Get(t *testing.T, key string) string {
+	var operands [][]byte
+	for _, run := range db.runs {
+		if value, ok := run[key]; ok {
+			operands = append(operands, value)
+		}
+	}
+	value, ok := db.op.FullMerge([]byte(key), nil, operands)
+	assert.True(t, ok)
+	return string(RenderJDR(value, 0))
+}
+
+func TestMergeOperator(t *testing.T) {
+	op := MergeOperator{}
+	doc := jdrOf(t, `{a:1@alice-2, b:"long text"@bob-2, s:{1 2 3}, t:yes@bob-2}`)
+	del := jdrOf(t, `{b:"long text"@bob-3, s:{3@bob-3}, t:yes@bob-3}`)
+	upd := jdrOf(t, `{a:2@alice-4}`)
+
+	res, ok := op.PartialMerge(nil, doc, del)
+	assert.True(t, ok)
+	assert.Equal(t,
+		`{(a 1@alice-2) (b "long text"@bob-3) (s {1 2 3@bob-3}) (t yes@bob-3)}`,
+		string(RenderJDR(res, 0)))
+
+	// the base is there: the deleted string goes, the deleted set
+	// element stays as it is the key of its own spot, the deleted
+	// term stays as an empty one does not render
+	res, ok = op.FullMerge(nil, doc, [][]byte{upd, del})
+	assert.True(t, ok)
+	assert.Equal(t,
+		`{(a 2@alice-4) (b ""@bob-3) (s {1 2 3@bob-3}) (t yes@bob-3)}`,
+		string(RenderJDR(res, 0)))
+	assert.Nil(t, Validate(res))
+	back, err := ParseNormalizeJDR(RenderJDR(res, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, res, []byte(back))
+
+	vm, err := op.NewValueMerger(nil, upd)
+	assert.Nil(t, err)
+	assert.Nil(t, vm.MergeOlder(del))
+	assert.Nil(t, vm.MergeOlder(doc))
+	partial, _, err := vm.Finish(false)
+	assert.Nil(t, err)
+	assert.Equal(t,
+		`{(a 2@alice-4) (b "long text"@bob-3) (s {1 2 3@bob-3}) (t yes@bob-3)}`,
+		string(RenderJDR(partial, 0)))
+	full, _, err := vm.Finish(true)
+	assert.Nil(t, err)
+	assert.Equal(t, res, full)
+
+	// a database-level delete leaves no existing value
+	res, ok = op.FullMerge(nil, nil, [][]byte{upd})
+	assert.True(t, ok)
+	assert.Equal(t, []byte(upd), res)
+
+	_, ok = op.PartialMerge(nil, doc, []byte{'i', 9})
+	assert.False(t, ok)
+}
+
+func TestMergeOperatorConvergence(t *testing.T) {
+	writes := map[string][]string{
+		"doc": {
+			`{a:1@alice-2, b:"x"@bob-2}`,
+			`{a:2@bob-4}`,
+			`{b:"gone"@bob-3}`,
+			`{c:{@carol-2 x:1}}`,
+			`{c:{x:2@alice-4, y:"y"@alice-2}}`,
+			`{c:{y:"y"@alice-3}}`,
+			`{a:2@bob-4}`,
+		},
+		"cnt": {
+			`<1@alice-2>`,
+			`<3@alice-4>`,
+			`<2@bob-2>`,
+			`<2@bob-3>`,
+			`<7@carol-6>`,
+		},
+		"set": {
+			`{1 2 3}`,
+			`{3@bob-3 4}`,
+			`{"five"@carol-2}`,
+			`{"five"@carol-3}`,
+		},
+	}
+	want := map[string]string{}
+	for key, list := range writes {
+		var operands [][]byte
+		for _, jdr := range list {
+			operands = append(operands, jdrOf(t, jdr))
+		}
+		value, ok := MergeOperator{}.FullMerge(nil, nil, operands)
+		assert.True(t, ok)
+		want[key] = string(RenderJDR(value, 0))
+		assert.NotContains(t, want[key], "gone")
+	}
+	type write struct {
+		key string
+		jdr string
+	}
+	var all []write
+	for key, list := range writes {
+		for _, jdr := range list {
+			all = append(all, write{key, jdr})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].key+all[i].jdr < all[j].key+all[j].jdr
+	})
+	for seed := int64(0); seed < 100; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		rnd.Shuffle(len(all), func(i, j int) {
+			all[i], all[j] = all[j], all[i]
+		})
+		db := lsmStore{}
+		for _, w := range all {
+			db.Merge(w.key, jdrOf(t, w.jdr))
+			for len(db.runs) > 1 && rnd.Intn(3) == 0 {
+				i := rnd.Intn(len(db.runs) - 1)
+				db.Compact(t, i, i+2+rnd.Intn(len(db.runs)-i-1))
+			}
+		}
+		for key := range writes {
+			assert.Equal(t, want[key], db.Get(t, key), "seed %d", seed)
+		}
+		db.Compact(t, 0, len(db.runs))
+		for key := range writes {
+			got := string(RenderJDR(db.runs[0][key], 0))
+			assert.Equal(t, want[key], got, "seed %d", seed)
+			assert.False(t, strings.Contains(got, "gone"))
+		}
+	}
+}
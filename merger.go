@@ -0,0 +1,294 @@
+package rdx
+
+import (
+	"math"
+	"sync"
+	"unicode/utf8"
+)
+
+// MergeContext is the scratch space for merging and normalizing: heaps,
+// value lists and TLV stacks, one set per nesting level, reused from call
+// to call. Once warmed up, a MergeContext merges and normalizes with no
+// allocations, except for the output growing. A MergeContext is not safe
+// for concurrent use; Merge, HeapMerge, Normalize and the like take one
+// from a pool.
+type MergeContext struct {
+	levels []*mergeLevel
+	depth  int
+	stack  Marks
+	out    []byte // the output of Normalize before it gets copied
+}
+
+type mergeLevel struct {
+	heap   Heap
+	queue  []int
+	vals   [][]byte
+	stack  Marks
+	chunks [][]byte
+	sorted []byte
+	at     Iter
+	next   Iter
+}
+
+// maxPooledOut limits the output buffers (Normalize, sorted chunks)
+// a pooled MergeContext may keep
+const maxPooledOut = 1 << 16
+
+var mergeContextPool = sync.Pool{
+	New: func() any { return &MergeContext{} },
+}
+
+func getMergeContext() *MergeContext {
+	return mergeContextPool.Get().(*MergeContext)
+}
+
+// putMergeContext returns the context to the pool, dropping the
+// references to the inputs, so a pooled context does not pin those
+func putMergeContext(m *MergeContext) {
+	m.depth = 0
+	if cap(m.out) > maxPooledOut {
+		m.out = nil
+	}
+	for _, l := range m.levels {
+		clear(l.heap[:cap(l.heap)])
+		clear(l.vals[:cap(l.vals)])
+		clear(l.chunks[:cap(l.chunks)])
+		l.heap, l.vals, l.chunks = l.heap[:0], l.vals[:0], l.chunks[:0]
+		l.at, l.next = Iter{}, Iter{}
+		if cap(l.sorted) > maxPooledOut {
+			l.sorted = nil
+		}
+	}
+	mergeContextPool.Put(m)
+}
+
+// enter returns the scratch space of the next nesting level
+func (m *MergeContext) enter() *mergeLevel {
+	if m.depth == len(m.levels) {
+		m.levels = append(m.levels, &mergeLevel{})
+	}
+	l := m.levels[m.depth]
+	m.depth++
+	return l
+}
+
+func (m *MergeContext) leave() {
+	m.depth--
+}
+
+// Merge is Merge using the scratch space of the MergeContext.
+func (m *MergeContext) Merge(data []byte, inputs [][]byte) ([]byte, error) {
+	return m.HeapMerge(data, inputs, CompareTuple)
+}
+
+// HeapMerge is HeapMerge using the scratch space of the MergeContext.
+func (m *MergeContext) HeapMerge(data []byte, inputs [][]byte, z Compare) (res []byte, err error) {
+	l := m.enter()
+	defer m.leave()
+	l.heap = l.heap[:0]
+	for _, r := range inputs {
+		if len(r) == 0 {
+			continue
+		}
+		l.heap = append(l.heap, NewIter(r))
+		if !l.heap[len(l.heap)-1].Read() {
+			err = l.heap[len(l.heap)-1].Error()
+			l.heap = l.heap[:len(l.heap)-1]
+			if err != nil {
+				return data, err
+			}
+			continue
+		}
+		l.heap.LastUp(z)
+	}
+	res = data
+	for len(l.heap) > 0 && err == nil {
+		var eqlen int
+		eqlen, l.queue = l.heap.eqUp(z, l.queue)
+		if eqlen == 1 {
+			res = append(res, l.heap[0].Record()...)
+		} else {
+			res, err = m.MergeSameSpot(res, l.heap[:eqlen])
+		}
+		if err == nil {
+			err = l.heap.nextEqs(eqlen, z)
+		}
+	}
+	return
+}
+
+// MergeSameSpot is MergeSameSpotElements using the scratch space of the
+// MergeContext.
+func (m *MergeContext) MergeSameSpot(data []byte, heap Heap) (ret []byte, err error) {
+	eq := 1
+	id := heap[0].ID()
+	for i := 1; i < len(heap); i++ {
+		var z int
+		if IsSame(&heap[0], &heap[i]) && IsPLEX(heap[0].Lit()) {
+			z = Eq
+		} else {
+			z = CompareLWW(&heap[0], &heap[i])
+		}
+		if z < Eq {
+			heap[0], heap[i] = heap[i], heap[0]
+			id = heap[0].ID()
+			eq = 1
+		} else if z > Eq {
+			pl := len(heap) - 1
+			heap[pl], heap[i] = heap[i], heap[pl]
+			heap = heap[:pl]
+			i--
+		} else {
+			heap[eq], heap[i] = heap[i], heap[eq]
+			if id.Less(heap[eq].ID()) {
+				id = heap[eq].ID()
+			}
+			eq++
+		}
+	}
+	eqs := heap[:eq]
+	lit := eqs[0].Lit()
+	l := m.enter()
+	defer m.leave()
+	l.vals = l.vals[:0]
+	l.stack = l.stack[:0]
+	ret = OpenTLV(data, lit, &l.stack)
+	var key [16]byte
+	n := putUint64Pair(&key, id.Seq, id.Src)
+	ret = append(ret, byte(n))
+	ret = append(ret, key[:n]...)
+	for i := range eqs {
+		l.vals = append(l.vals, eqs[i].Value())
+	}
+	switch lit {
+	case LitFloat:
+		ret, err = mergeValuesF(ret, l.vals)
+	case LitInteger:
+		ret, err = mergeValuesI(ret, l.vals)
+	case LitReference:
+		ret, err = mergeValuesR(ret, l.vals)
+	case LitString:
+		ret, err = mergeValuesS(ret, l.vals)
+	case LitTerm:
+		ret, err = mergeValuesT(ret, l.vals)
+	case LitTuple:
+		ret, err = m.HeapMerge(ret, l.vals, CompareTuple)
+	case LitLinear:
+		ret, err = m.HeapMerge(ret, l.vals, CompareLinear)
+	case LitEuler:
+		ret, err = m.HeapMerge(ret, l.vals, CompareEuler)
+	case LitMultix:
+		ret, err = m.HeapMerge(ret, l.vals, CompareMultix)
+	default:
+		ret, err = nil, ErrBadRDXRecord
+	}
+	if err == nil {
+		ret, err = CloseTLV(ret, lit, &l.stack)
+	}
+	return
+}
+
+// Normalize appends the normalized data, see Normalize.
+func (m *MergeContext) Normalize(data, rdx []byte) (norm Stream, err error) {
+	m.stack = m.stack[:0]
+	return m.normalize(data, rdx, nil)
+}
+
+func (m *MergeContext) normalize(data, rdx []byte, z Compare) (norm Stream, err error) {
+	norm = data
+	if len(rdx) == 0 {
+		return
+	}
+	l := m.enter()
+	defer m.leave()
+	l.chunks = l.chunks[:0]
+	l.at = NewIter(rdx)
+	l.at.Read()
+	l.next = l.at
+	oc := len(norm)
+	for l.at.HasData() && err == nil {
+		norm, err = m.appendNorm(norm, &l.at)
+		l.next.Read()
+		if err == nil && l.next.HasData() && z != nil && z(&l.at, &l.next) != Less {
+			l.chunks = append(l.chunks, norm[oc:])
+			oc = len(norm)
+		}
+		l.at = l.next
+	}
+	if l.at.HasFailed() {
+		err = l.next.Error()
+	}
+	if len(l.chunks) > 0 && err == nil {
+		l.chunks = append(l.chunks, norm[oc:])
+		l.sorted, err = m.HeapMerge(l.sorted[:0], l.chunks, z)
+		norm = append(data, l.sorted...)
+	}
+	return
+}
+
+func (m *MergeContext) appendNorm(to []byte, it *Iter) (norm []byte, err error) {
+	val := it.Value()
+	lit := it.Lit()
+	id := it.ID()
+	var key [16]byte
+	idbytes := key[:putUint64Pair(&key, id.Seq, id.Src)]
+	var num [8]byte
+	var ref [16]byte
+	norm = to
+	switch lit {
+	case LitFloat:
+		if len(val) > 8 {
+			return nil, ErrBadFloatRecord
+		}
+		if math.IsNaN(UnzipFloat64(val)) {
+			return nil, ErrBadFloatRecord
+		}
+		norm = WriteTLKV(norm, lit, idbytes, num[:putUint64(&num, UnzipUint64(val))])
+	case LitInteger:
+		if len(val) > 8 {
+			return nil, ErrBadIntegerRecord
+		}
+		norm = WriteTLKV(norm, lit, idbytes, num[:putUint64(&num, UnzipUint64(val))])
+	case LitReference:
+		if len(val) > 16 { // todo bad sizes
+			return nil, ErrBadReferenceRecord
+		}
+		seq, src := UnzipUint64Pair(val)
+		norm = WriteTLKV(norm, lit, idbytes, ref[:putUint64Pair(&ref, seq, src)])
+	case LitString:
+		if !utf8.Valid(val) {
+			return nil, ErrBadStringRecord
+		}
+		norm = WriteTLKV(norm, lit, idbytes, val)
+	case LitTerm:
+		for _, c := range val {
+			if RON64REV[c] == 0xff {
+				return nil, ErrBadTermRecord
+			}
+		}
+		norm = WriteTLKV(norm, lit, idbytes, val)
+	case LitTuple, LitLinear, LitEuler, LitMultix:
+		var z Compare
+		switch lit {
+		case LitEuler:
+			z = CompareEuler
+		case LitMultix:
+			z = CompareMultix
+		}
+		plit := m.stack.TopLit()
+		norm = OpenTLV(norm, lit, &m.stack)
+		norm = append(norm, byte(len(idbytes)))
+		norm = append(norm, idbytes...)
+		l := len(norm)
+		norm, err = m.normalize(norm, val, z)
+		if err != nil {
+			return nil, err
+		}
+		if lit == LitTuple && plit == LitEuler && l == len(norm) { // ()
+			norm, err = CancelTLV(norm, LitTuple, &m.stack)
+		} else {
+			norm, err = CloseTLV(norm, lit, &m.stack)
+		}
+	}
+	return
+}
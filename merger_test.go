@@ -0,0 +1,135 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	mergerSampleA = `{a:1, b:{x:1 y:2}, c:[1 2 3], d:<1@alice-2 2@bob-4>} 5 "str"`
+	mergerSampleB = `{a:2@bob-4, b:{x:3@bob-4 z:2}, c:[1 2 3], e:1} 6 "sts"`
+	mergerSampleC = `{e:2@bob-4, b:{z:3@bob-4 x:2}, c:[1 2 3], a:1} 6 "sts" 1.5 alice-4`
+)
+
+func TestMergeContextAllocs(t *testing.T) {
+	a, err := ParseNormalizeJDR([]byte(mergerSampleA))
+	assert.Nil(t, err)
+	b, err := ParseNormalizeJDR([]byte(mergerSampleB))
+	assert.Nil(t, err)
+	raw, err := ParseJDR([]byte(mergerSampleC))
+	assert.Nil(t, err)
+	inputs := [][]byte{a, b}
+
+	var m MergeContext
+	want, err := Merge(nil, inputs)
+	assert.Nil(t, err)
+	out := make([]byte, 0, 1024)
+	out, err = m.Merge(out, inputs)
+	assert.Nil(t, err)
+	assert.Equal(t, want, out)
+	allocs := testing.AllocsPerRun(100, func() {
+		out, _ = m.Merge(out[:0], inputs)
+	})
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, want, out)
+
+	want, err = Normalize(raw)
+	assert.Nil(t, err)
+	out, err = m.Normalize(out[:0], raw)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(want), []byte(out))
+	allocs = testing.AllocsPerRun(100, func() {
+		out, _ = m.Normalize(out[:0], raw)
+	})
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, []byte(want), []byte(out))
+}
+
+// The package-level functions take a MergeContext from a pool; a GC
+// may empty the pool, hence the allowance of a fraction.
+func TestMergeAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items on purpose under the race detector")
+	}
+	a, err := ParseNormalizeJDR([]byte(mergerSampleA))
+	assert.Nil(t, err)
+	b, err := ParseNormalizeJDR([]byte(mergerSampleB))
+	assert.Nil(t, err)
+	raw, err := ParseJDR([]byte(mergerSampleC))
+	assert.Nil(t, err)
+	inputs := [][]byte{a, b}
+
+	want, err := Merge(nil, inputs)
+	assert.Nil(t, err)
+	out := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		out, _ = Merge(out[:0], inputs)
+	})
+	assert.Less(t, allocs, 1.0)
+	assert.Equal(t, want, out)
+	allocs = testing.AllocsPerRun(100, func() {
+		out, _ = HeapMerge(out[:0], inputs, CompareTuple)
+	})
+	assert.Less(t, allocs, 1.0)
+	assert.Equal(t, want, out)
+
+	var norm []byte
+	allocs = testing.AllocsPerRun(100, func() {
+		norm, _ = Normalize(raw)
+	})
+	assert.Less(t, allocs, 2.0) // the result
+	var m MergeContext
+	want, err = m.Normalize(nil, raw)
+	assert.Nil(t, err)
+	assert.Equal(t, want, norm)
+	assert.Equal(t, len(norm), cap(norm))
+}
+
+func TestPutMergeContext(t *testing.T) {
+	a, err := ParseNormalizeJDR([]byte(mergerSampleA))
+	assert.Nil(t, err)
+	b, err := ParseNormalizeJDR([]byte(mergerSampleB))
+	assert.Nil(t, err)
+	m := &MergeContext{}
+	_, err = m.Merge(nil, [][]byte{a, b})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, m.levels)
+	m.levels[0].sorted = make([]byte, 0, maxPooledOut+1)
+	putMergeContext(m)
+	for _, l := range m.levels {
+		for _, it := range l.heap[:cap(l.heap)] {
+			assert.Nil(t, it.data)
+		}
+		for _, v := range l.vals[:cap(l.vals)] {
+			assert.Nil(t, v)
+		}
+		assert.Nil(t, l.at.data)
+		assert.Nil(t, l.next.data)
+	}
+	assert.Nil(t, m.levels[0].sorted)
+}
+
+func BenchmarkMerge(b *testing.B) {
+	x, _ := ParseNormalizeJDR([]byte(mergerSampleA))
+	y, _ := ParseNormalizeJDR([]byte(mergerSampleB))
+	inputs := [][]byte{x, y}
+	var m MergeContext
+	out := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out, _ = m.Merge(out[:0], inputs)
+	}
+}
+
+func BenchmarkNormalize(b *testing.B) {
+	raw, _ := ParseJDR([]byte(mergerSampleC))
+	var m MergeContext
+	out := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out, _ = m.Normalize(out[:0], raw)
+	}
+}
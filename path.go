@@ -0,0 +1,211 @@
+package rdx
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PathError reports the path step that failed, see Delve.
+type PathError struct {
+	Step int
+	Key  Stream
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	msg := "path step " + strconv.Itoa(e.Step)
+	if len(e.Key) > 0 {
+		msg += " " + strings.TrimSpace(string(RenderJDR(e.Key, 0)))
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// pickIn finds the live element of a container body for a path step
+func pickIn(lit byte, body []byte, key *Iter) (found Iter, err error) {
+	it := NewIter(body)
+	switch lit {
+	case LitTuple:
+		if key.Lit() != LitInteger {
+			return Iter{}, ErrBadPath
+		}
+		for n := key.Integer(); n >= 0 && it.Read(); n-- {
+		}
+	case LitLinear:
+		switch key.Lit() {
+		case LitInteger:
+			n := key.Integer()
+			for n >= 0 && it.Read() {
+				if it.IsLive() {
+					n--
+				}
+			}
+		case LitReference:
+			ref := key.Reference().Base()
+			for it.Read() && it.ID().Base() != ref {
+			}
+		default:
+			return Iter{}, ErrBadPath
+		}
+	case LitEuler:
+		for it.Read() && CompareEuler(&it, key) != Eq {
+		}
+	case LitMultix:
+		src := key.ID().Src
+		if key.Lit() == LitReference {
+			src = key.Reference().Src
+		}
+		for it.Read() && it.ID().Src != src {
+		}
+	default:
+		return Iter{}, ErrNotPLEX
+	}
+	if it.HasFailed() {
+		return Iter{}, it.Error()
+	}
+	if !it.HasData() || !it.IsLive() {
+		return Iter{}, ErrRecordNotFound
+	}
+	return it, nil
+}
+
+// entryValue resolves a map entry (key value) to the value
+func entryValue(entry Iter) Iter {
+	if entry.Lit() != LitTuple {
+		return entry
+	}
+	in := entry.Inner()
+	if in.Read() && in.Read() && !in.HasMore() {
+		return in
+	}
+	return entry
+}
+
+// ParsePath compiles a textual path into a path for Lookup, e.g.
+// `users.{"alice"}.emails[2]` or `config:timeout`. The path starts at
+// the root (the first top-level element), so the compiled path starts
+// with 0 and the first textual step is step 1. Steps are separated
+// by `.` or `:`, bracketed steps may follow each other directly:
+//
+//	name       a Term key; a decimal number is an Integer, i.e. an index
+//	{jdr}      a key given in JDR, e.g. {"alice"} or {1}
+//	[2]        a position in a Tuple or a Linear (live elements only)
+//	[alice-4]  a Linear element by its ID
+//	<alice>    a Multix element by its source
+func ParsePath(path string) (ret Stream, err error) {
+	ret = WriteRDX(nil, LitInteger, ID0, ZipInt64(0))
+	n, sep := 1, true
+	for i := 0; i < len(path); {
+		c := path[i]
+		if c == '.' || c == ':' {
+			if sep {
+				return nil, &PathError{Step: n, Err: ErrBadPath}
+			}
+			i, sep = i+1, true
+			continue
+		}
+		j := 0
+		switch c {
+		case '{', '[', '<':
+			if j = pathClose(path, i); j < 0 {
+				return nil, &PathError{Step: n, Err: ErrBadPath}
+			}
+			ret, err = appendPathStep(ret, c, path[i+1:j])
+			j++
+		default:
+			if !sep {
+				return nil, &PathError{Step: n, Err: ErrBadPath}
+			}
+			j = i + strings.IndexAny(path[i:]+".", ".:{[<")
+			ret = appendPathName(ret, path[i:j])
+		}
+		if err != nil {
+			return nil, &PathError{Step: n, Err: err}
+		}
+		i, sep = j, false
+		n++
+	}
+	if sep && n > 1 {
+		return nil, &PathError{Step: n, Err: ErrBadPath}
+	}
+	return ret, nil
+}
+
+// Lookup is Delve with a textual path, see ParsePath. Unlike Delve,
+// map entries resolve to their values, so `config:timeout` finds 30
+// in {config:{timeout:30}}.
+func Lookup(data Stream, path string) (found Stream, err error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return delve(data, p, true)
+}
+
+// pathClose finds the bracket closing the one at i; quotes and
+// nested brackets of the same kind are skipped
+func pathClose(path string, i int) int {
+	open := path[i]
+	shut := map[byte]byte{'{': '}', '[': ']', '<': '>'}[open]
+	depth, quote, esc := 0, false, false
+	for ; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case esc:
+			esc = false
+		case quote:
+			esc = c == '\\'
+			quote = c != '"'
+		case c == '"':
+			quote = true
+		case c == open:
+			depth++
+		case c == shut:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func appendPathName(data []byte, name string) []byte {
+	if n, err := strconv.ParseInt(name, 10, 64); err == nil {
+		return WriteRDX(data, LitInteger, ID0, ZipInt64(n))
+	}
+	return WriteRDX(data, LitTerm, ID0, []byte(name))
+}
+
+func appendPathStep(data []byte, bracket byte, inner string) ([]byte, error) {
+	switch bracket {
+	case '{':
+		key, err := ParseJDR([]byte(inner))
+		if err != nil {
+			return nil, err
+		}
+		it := NewIter(key)
+		if !it.Read() || it.HasMore() {
+			return nil, ErrBadPath
+		}
+		return WriteRDX(data, LitTuple, ID0, key), nil
+	case '[':
+		if n, err := strconv.ParseInt(inner, 10, 64); err == nil {
+			return WriteRDX(data, LitInteger, ID0, ZipInt64(n)), nil
+		}
+		id, err := NewID([]byte(inner))
+		if err != nil {
+			return nil, err
+		}
+		return WriteRDX(data, LitReference, ID0, ZipID(id)), nil
+	default: // '<'
+		src, rest := ParseRON64([]byte(inner))
+		if len(inner) == 0 || len(rest) > 0 || src > Mask60bit {
+			return nil, ErrBadPath
+		}
+		return WriteRDX(data, LitReference, ID0, ZipID(ID{Src: src})), nil
+	}
+}
@@ -0,0 +1,85 @@
+package rdx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	doc, err := ParseNormalizeJDR([]byte(`{
+		users: {
+			"alice": {emails: ["a@x.org" "alice@y.com" "al@z.net"], age: 33},
+			"bob": {emails: []},
+		},
+		config: {timeout: 30, hosts: (a b c)},
+		hits: <1@alice-2 5@bob-4>,
+		2: two,
+	}`))
+	assert.Nil(t, err)
+	cases := [][2]string{
+		{`users.{"alice"}.emails[2]`, `"al@z.net"`},
+		{`users{"alice"}age`, ""},
+		{`config:timeout`, `30`},
+		{`config.hosts[1]`, `b`},
+		{`config.hosts.1`, `b`},
+		{`hits<bob>`, `5@bob-4`},
+		{`{2}`, `two`},
+		{`2`, `two`},
+	}
+	for _, c := range cases {
+		found, err := Lookup(doc, c[0])
+		if c[1] == "" {
+			assert.NotNil(t, err, c[0])
+			continue
+		}
+		assert.Nil(t, err, c[0])
+		assert.Equal(t, c[1], string(RenderJDR(found, 0)), c[0])
+	}
+
+	_, err = Lookup(doc, `users.{"carol"}.emails`)
+	var pe *PathError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, 2, pe.Step)
+	assert.True(t, errors.Is(err, ErrRecordNotFound))
+	assert.Equal(t, `path step 2 ("carol"): no such record`, err.Error())
+	_, err = Lookup(doc, `config.timeout.x`)
+	assert.True(t, errors.Is(err, ErrNotPLEX))
+	_, err = Lookup(doc, `config..timeout`)
+	assert.True(t, errors.Is(err, ErrBadPath))
+	_, err = Lookup(doc, `config[`)
+	assert.True(t, errors.Is(err, ErrBadPath))
+
+	list, err := ParseJDR([]byte("[a@alice-10 b@bob-20 c@alice-30]"))
+	assert.Nil(t, err)
+	found, err := Lookup(list, "[bob-20]")
+	assert.Nil(t, err)
+	assert.Equal(t, "b@bob-20", string(RenderJDR(found, 0)))
+	found, err = Lookup(list, "[2]")
+	assert.Nil(t, err)
+	assert.Equal(t, "c@alice-30", string(RenderJDR(found, 0)))
+
+	found, err = Delve(jdrOf(t, "(1 (2 3))"), jdrOf(t, "0 1 0"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2", string(RenderJDR(found, 0)))
+	// Delve stops at map entries, Lookup goes on to the values
+	found, err = Delve(doc, jdrOf(t, "0 config 1 timeout"))
+	assert.Nil(t, err)
+	assert.Equal(t, "(timeout 30)", string(RenderJDR(found, 0)))
+	found, err = Delve(doc, jdrOf(t, "0 config 1 timeout 1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "30", string(RenderJDR(found, 0)))
+	_, err = Delve(doc, jdrOf(t, "0 config timeout"))
+	assert.ErrorIs(t, err, ErrBadPath)
+	found, err = Pick(I0(1), jdrOf(t, "(1 (2 3))"))
+	assert.Nil(t, err)
+	assert.Equal(t, "(2 3)", string(RenderJDR(found, 0)))
+	// a Linear position counts live elements, tombstones are not found
+	list = jdrOf(t, "[a@alice-10 b@bob-21 c@alice-30]")
+	found, err = Pick(I0(1), list)
+	assert.Nil(t, err)
+	assert.Equal(t, "c@alice-30", string(RenderJDR(found, 0)))
+	_, err = Pick(jdrOf(t, "bob-20"), list)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
@@ -0,0 +1,391 @@
+package rdx
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrBadQuery = errors.New("bad query")
+
+// Query is a compiled selector, see CompileQuery.
+type Query struct {
+	steps []queryStep
+}
+
+const (
+	stepKey = iota
+	stepAll
+	stepDeep
+	stepFilter
+)
+
+type queryStep struct {
+	kind   int
+	key    Stream
+	dead   bool // wildcards list tombstones too
+	filter func(it *Iter) bool
+}
+
+// Select returns all the elements of a document matching the query,
+// in the document order, see CompileQuery. The returned records point
+// into the document.
+func Select(doc Stream, query string) (found []Stream, err error) {
+	q, err := CompileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	m := q.Matches(doc)
+	for m.Read() {
+		found = append(found, m.Record())
+	}
+	return found, m.Error()
+}
+
+// CompileQuery compiles a selector. A query is a path (see ParsePath)
+// that may also have wildcards and filters, e.g. `users.*.emails.*` or
+// `**[?src=bob]`. It applies to every top-level element.
+//
+//	x.*        every child of x; map entries resolve to values
+//	x.**       x itself and all its descendants, raw
+//	x.*~ x.**~ same, tombstones included
+//	x[*]       same as x.*
+//	x[?cond]   x if the condition holds
+//
+// A condition is a comparison, a flag or a combination of those with
+// && and || (no parentheses, && goes first):
+//
+//	type=PL    the type is one of the letters
+//	src=bob    stamped by the source; id=bob-4 is the exact stamp
+//	live dead  tombstone or not
+//	>10        the element compared to a JDR value: = != < <= > >=
+//	1>10       same for the child of a tuple: 0 is the key of a map entry
+//
+// Values of different types are not ordered and not equal: across types,
+// = and the orderings are false while != is true.
+func CompileQuery(query string) (q *Query, err error) {
+	q = &Query{}
+	sep := true
+	for i := 0; i < len(query); {
+		n := len(q.steps)
+		c := query[i]
+		if c == '.' || c == ':' {
+			if sep {
+				return nil, &PathError{Step: n, Err: ErrBadQuery}
+			}
+			i, sep = i+1, true
+			continue
+		}
+		step := queryStep{kind: stepKey}
+		j := i + 1
+		switch {
+		case c == '*':
+			if !sep {
+				return nil, &PathError{Step: n, Err: ErrBadQuery}
+			}
+			step.kind = stepAll
+			if j < len(query) && query[j] == '*' {
+				step.kind = stepDeep
+				j++
+			}
+			if j < len(query) && query[j] == '~' {
+				step.dead = true
+				j++
+			}
+		case c == '{' || c == '[' || c == '<':
+			if j = pathClose(query, i); j < 0 {
+				return nil, &PathError{Step: n, Err: ErrBadQuery}
+			}
+			inner := query[i+1 : j]
+			if c == '[' && inner == "*" {
+				step.kind = stepAll
+			} else if c == '[' && strings.HasPrefix(inner, "?") {
+				step.kind = stepFilter
+				step.filter, err = compileFilter(inner[1:])
+			} else {
+				step.key, err = appendPathStep(nil, c, inner)
+			}
+			j++
+		default:
+			if !sep {
+				return nil, &PathError{Step: n, Err: ErrBadQuery}
+			}
+			j = i + strings.IndexAny(query[i:]+".", ".:{[<*")
+			step.key = appendPathName(nil, query[i:j])
+		}
+		if err != nil {
+			return nil, &PathError{Step: n, Err: err}
+		}
+		q.steps = append(q.steps, step)
+		i, sep = j, false
+	}
+	if sep && len(q.steps) > 0 {
+		return nil, &PathError{Step: len(q.steps), Err: ErrBadQuery}
+	}
+	return q, nil
+}
+
+// splitTop splits by the separator outside of quotes and brackets
+func splitTop(s, sep string) (parts []string) {
+	depth, quote, esc, from := 0, false, false, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case esc:
+			esc = false
+		case quote:
+			esc = c == '\\'
+			quote = c != '"'
+		case c == '"':
+			quote = true
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[from:i])
+			from = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[from:])
+}
+
+func compileFilter(expr string) (filter func(it *Iter) bool, err error) {
+	var ors [][]func(it *Iter) bool
+	for _, or := range splitTop(expr, "||") {
+		var all []func(it *Iter) bool
+		for _, and := range splitTop(or, "&&") {
+			cond, err := compileCond(strings.TrimSpace(and))
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, cond)
+		}
+		ors = append(ors, all)
+	}
+	return func(it *Iter) bool {
+		for _, all := range ors {
+			ok := true
+			for _, cond := range all {
+				ok = ok && cond(it)
+			}
+			if ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+var queryOps = []string{"!=", "<=", ">=", "=", "<", ">"}
+
+func compileCond(cond string) (func(it *Iter) bool, error) {
+	switch cond {
+	case "live":
+		return func(it *Iter) bool { return it.IsLive() }, nil
+	case "dead":
+		return func(it *Iter) bool { return !it.IsLive() }, nil
+	}
+	i, op := len(cond), ""
+	for _, o := range queryOps {
+		if k := strings.Index(cond, o); k >= 0 && k < i {
+			i, op = k, o
+		}
+	}
+	if op == "" {
+		return nil, ErrBadQuery
+	}
+	lhs := strings.TrimSpace(cond[:i])
+	rhs := strings.TrimSpace(cond[i+len(op):])
+	switch lhs {
+	case "type":
+		if op != "=" && op != "!=" || rhs == "" {
+			return nil, ErrBadQuery
+		}
+		return func(it *Iter) bool {
+			return strings.IndexByte(rhs, it.Lit()) >= 0 == (op == "=")
+		}, nil
+	case "src":
+		src, rest := ParseRON64([]byte(rhs))
+		if len(rest) > 0 || (op != "=" && op != "!=") {
+			return nil, ErrBadQuery
+		}
+		return func(it *Iter) bool {
+			return (it.ID().Src == src) == (op == "=")
+		}, nil
+	case "id":
+		id, err := NewID([]byte(rhs))
+		if err != nil || (op != "=" && op != "!=") {
+			return nil, ErrBadQuery
+		}
+		return func(it *Iter) bool {
+			return (it.ID() == id) == (op == "=")
+		}, nil
+	}
+	child := -1
+	if lhs != "" {
+		n, err := strconv.Atoi(lhs)
+		if err != nil || n < 0 {
+			return nil, ErrBadQuery
+		}
+		child = n
+	}
+	val, err := ParseJDR([]byte(rhs))
+	if err != nil {
+		return nil, err
+	}
+	vi := NewIter(val)
+	if !vi.Read() || vi.HasMore() {
+		return nil, ErrBadQuery
+	}
+	return func(it *Iter) bool {
+		el := *it
+		if child >= 0 {
+			if it.Lit() != LitTuple {
+				return false
+			}
+			el = it.Inner()
+			for n := child; n >= 0 && el.Read(); n-- {
+			}
+			if !el.HasData() || !el.IsLive() {
+				return false
+			}
+		}
+		if el.Lit() != vi.Lit() {
+			return op == "!="
+		}
+		z := CompareValue(&el, &vi)
+		switch op {
+		case "=":
+			return z == Eq
+		case "!=":
+			return z != Eq
+		case "<":
+			return z < Eq
+		case "<=":
+			return z <= Eq
+		case ">":
+			return z > Eq
+		default:
+			return z >= Eq
+		}
+	}, nil
+}
+
+// Matches iterates over the matches of a query in a document. It goes
+// depth first, so memory use is proportional to the depth, not the size.
+type Matches struct {
+	q     *Query
+	stack []queryFrame
+	match Iter
+	err   error
+}
+
+// queryFrame is either one element or a container's children
+// to apply a step to
+type queryFrame struct {
+	it      Iter
+	step    int
+	one     bool
+	dead    bool
+	resolve bool
+}
+
+func (q *Query) Matches(doc Stream) *Matches {
+	return &Matches{
+		q:     q,
+		stack: []queryFrame{{it: NewIter(doc)}},
+	}
+}
+
+// Read advances to the next match; false on the end or on an error.
+func (m *Matches) Read() bool {
+	for len(m.stack) > 0 && m.err == nil {
+		top := len(m.stack) - 1
+		f := &m.stack[top]
+		if f.one {
+			el, step := f.it, f.step
+			m.stack = m.stack[:top]
+			if m.visit(el, step) {
+				return true
+			}
+			continue
+		}
+		if !f.it.Read() {
+			if f.it.HasFailed() {
+				m.err = f.it.Error()
+			}
+			m.stack = m.stack[:top]
+			continue
+		}
+		if !f.dead && !f.it.IsLive() {
+			continue
+		}
+		el, step := f.it, f.step
+		if f.resolve {
+			el = entryValue(el)
+		}
+		if m.visit(el, step) {
+			return true
+		}
+	}
+	m.match = Iter{}
+	return false
+}
+
+func (m *Matches) visit(el Iter, k int) bool {
+	if k == len(m.q.steps) {
+		m.match = el
+		return true
+	}
+	step := &m.q.steps[k]
+	switch step.kind {
+	case stepKey:
+		if !IsPLEX(el.Lit()) {
+			return false
+		}
+		key := NewIter(step.key)
+		key.Read()
+		found, err := pickIn(el.Lit(), el.Value(), &key)
+		if err == ErrRecordNotFound || err == ErrBadPath {
+			return false
+		} else if err != nil {
+			m.err = err
+			return false
+		}
+		if el.Lit() == LitEuler {
+			found = entryValue(found)
+		}
+		m.stack = append(m.stack, queryFrame{it: found, step: k + 1, one: true})
+	case stepAll:
+		if IsPLEX(el.Lit()) {
+			m.stack = append(m.stack, queryFrame{it: el.Inner(), step: k + 1,
+				dead: step.dead, resolve: el.Lit() == LitEuler})
+		}
+	case stepDeep:
+		if IsPLEX(el.Lit()) {
+			m.stack = append(m.stack, queryFrame{it: el.Inner(), step: k, dead: step.dead})
+		}
+		m.stack = append(m.stack, queryFrame{it: el, step: k + 1, one: true})
+	case stepFilter:
+		if step.filter(&el) {
+			m.stack = append(m.stack, queryFrame{it: el, step: k + 1, one: true})
+		}
+	}
+	return false
+}
+
+// Record returns the current match.
+func (m *Matches) Record() Stream {
+	return m.match.Record()
+}
+
+// Iter returns the current match as an iterator positioned on it.
+func (m *Matches) Iter() Iter {
+	return m.match
+}
+
+func (m *Matches) Error() error {
+	return m.err
+}
@@ -0,0 +1,66 @@
+package rdx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelect(t *testing.T) {
+	doc, err := ParseNormalizeJDR([]byte(`{
+		users: {
+			"alice": {emails: ["a@x.org" "alice@y.com"], age: 33},
+			"bob": {emails: ["b@z.net"], age: 7@bob-2},
+			"carol": {emails: [], age: 51},
+		},
+		scores: [(a 5) (b 12) (c 30@bob-4) (d 11@carol-3)],
+	}`))
+	assert.Nil(t, err)
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{`users.*.emails.*`, []string{`"a@x.org"`, `"alice@y.com"`, `"b@z.net"`}},
+		{`users.*.age[?>10]`, []string{`33`, `51`}},
+		{`scores.*[?type=P && 1>10]`, []string{`(b 12)`, `(c 30@bob-4)`}},
+		{`**[?src=bob]`, []string{`30@bob-4`, `7@bob-2`}},
+		{`**~[?dead]`, []string{`11@carol-3`}},
+		{`**[?type=I][?>50 || <10]`, []string{`5`, `7@bob-2`, `51`}},
+		{`users{"bob"}.emails[0]`, []string{`"b@z.net"`}},
+		{`users{"dave"}.emails[0]`, nil},
+		{`users.*.phones.*`, nil},
+		{`scores[*][?0=b || 0=d]`, []string{`(b 12)`, `(d 11@carol-3)`}},
+		{`users.*.age[?!="x"]`, []string{`33`, `7@bob-2`, `51`}},
+		{`users.*.age[?="x" || <"x"]`, nil},
+	}
+	for _, c := range cases {
+		found, err := Select(doc, c.query)
+		assert.Nil(t, err, c.query)
+		var got []string
+		for _, f := range found {
+			got = append(got, string(RenderJDR(f, 0)))
+		}
+		assert.Equal(t, c.want, got, c.query)
+	}
+	bad := []string{
+		"users..age",
+		"users[?age]",
+		"**[?type=I && (>50 || <10)]",
+		"**[?type=I] [?>50 || <10]",
+	}
+	for _, query := range bad {
+		_, err = Select(doc, query)
+		assert.True(t, errors.Is(err, ErrBadQuery), query)
+	}
+
+	q, err := CompileQuery("**[?type=S]")
+	assert.Nil(t, err)
+	m := q.Matches(doc)
+	n := 0
+	for m.Read() {
+		n++
+	}
+	assert.Nil(t, m.Error())
+	assert.Equal(t, 6, n) // emails and user names
+}
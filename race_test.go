@@ -0,0 +1,5 @@
+//go:build race
+
+package rdx
+
+const raceEnabled = true
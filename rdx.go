@@ -3,11 +3,9 @@ package rdx
 import (
 	"bytes"
 	"errors"
-	"math"
 	"math/bits"
 	"os"
 	"sort"
-	"unicode/utf8"
 )
 
 const (
@@ -131,86 +129,15 @@ func mergeElementsP(data []byte, bare [][]byte) (ret []byte, err error) {
 	return HeapMerge(data, bare, CompareTuple)
 }
 
-func mergeElementsL(data []byte, bare [][]byte) ([]byte, error) {
-	return HeapMerge(data, bare, CompareLinear)
-}
-
-func mergeElementsE(data []byte, bare [][]byte) ([]byte, error) {
-	return HeapMerge(data, bare, CompareEuler)
-}
-
-func mergeElementsX(data []byte, bare [][]byte) ([]byte, error) {
-	return HeapMerge(data, bare, CompareMultix)
-}
-
 // same element, maybe different revision
 func IsSame(a, b *Iter) bool {
 	return a.Lit() == b.Lit() && a.ID().Base() == b.ID().Base()
 }
 
 func MergeSameSpotElements(data []byte, heap Heap) (ret []byte, err error) {
-	eq := 1
-	id := heap[0].ID()
-	for i := 1; i < len(heap); i++ {
-		var z int
-		if IsSame(&heap[0], &heap[i]) && IsPLEX(heap[0].Lit()) {
-			z = Eq
-		} else {
-			z = CompareLWW(&heap[0], &heap[i])
-		}
-		if z < Eq {
-			heap[0], heap[i] = heap[i], heap[0]
-			id = heap[0].ID()
-			eq = 1
-		} else if z > Eq {
-			pl := len(heap) - 1
-			heap[pl], heap[i] = heap[i], heap[pl]
-			heap = heap[:pl]
-			i--
-		} else {
-			heap[eq], heap[i] = heap[i], heap[eq]
-			if id.Less(heap[eq].ID()) {
-				id = heap[eq].ID()
-			}
-			eq++
-		}
-	}
-	eqs := heap[:eq]
-	lit := eqs[0].Lit()
-	vals := make([][]byte, 0, MaxInputs)
-	stack := make(Marks, 0, 16)
-	ret = OpenTLV(data, lit, &stack)
-	key := ZipID(id)
-	ret = append(ret, byte(len(key)))
-	ret = append(ret, key...) // TODO
-	for _, val := range eqs {
-		vals = append(vals, val.Value())
-	} // FIXME 1
-	switch lit {
-	case LitFloat:
-		ret, err = mergeValuesF(ret, vals)
-	case LitInteger:
-		ret, err = mergeValuesI(ret, vals)
-	case LitReference:
-		ret, err = mergeValuesR(ret, vals)
-	case LitString:
-		ret, err = mergeValuesS(ret, vals)
-	case LitTerm:
-		ret, err = mergeValuesT(ret, vals)
-	case LitTuple:
-		ret, err = mergeElementsP(ret, vals)
-	case LitLinear:
-		ret, err = mergeElementsL(ret, vals)
-	case LitEuler:
-		ret, err = mergeElementsE(ret, vals)
-	case LitMultix:
-		ret, err = mergeElementsX(ret, vals)
-	default:
-		ret, err = nil, ErrBadRDXRecord
-	}
-	if err == nil {
-		ret, err = CloseTLV(ret, lit, &stack)
-	}
+	m := getMergeContext()
+	ret, err = m.MergeSameSpot(data, heap)
+	putMergeContext(m)
 	return
 }
 
@@ -663,128 +590,14 @@ var ErrBadTermRecord = errors.New("bad Term record format")
 // Normalizes a raw Stream input (all keys Value order, no duplicates, no overlong
 // encoding, etc etc. Inputs that are *certainly* normalized get mentioned as
 // `rdx.Stream` while not-necessarily-normalized go as `[]byte`.
+// The only allocation is the result, see MergeContext.Normalize.
 func Normalize(rdx []byte) (RDX []byte, err error) {
-	data := make([]byte, 0, len(rdx))
-	stack := Marks{}
-	return normalize(data, rdx, nil, &stack)
-}
-
-func normalize(data, rdx []byte, z Compare, stack *Marks) (norm Stream, err error) {
-	norm = data
-	if len(rdx) == 0 {
-		return
-	}
-	chunks := [][]byte{}
-	at := NewIter(rdx)
-	at.Read()
-	next := at
-	oc := len(norm)
-	for at.HasData() && err == nil {
-		norm, err = appendNorm(norm, at, stack)
-		next.Read()
-		if err == nil && next.HasData() && z != nil && z(&at, &next) != Less {
-			chunks = append(chunks, norm[oc:])
-			oc = len(norm)
-		}
-		at = next
-	}
-	if at.HasFailed() {
-		err = next.Error()
-	}
-	if len(chunks) > 0 && err == nil {
-		chunks = append(chunks, norm[oc:])
-		sorted := make([]byte, 0, len(norm)-len(data))
-		sorted, err = HeapMerge(sorted, chunks, z)
-		norm = append(data, sorted...)
-	}
-	return
-}
-
-func appendNorm(to []byte, it Iter, stack *Marks) (norm []byte, err error) {
-	val := it.Value()
-	lit := it.Lit()
-	idbytes := ZipID(it.ID())
-	norm = to
-	switch lit {
-	case LitFloat:
-		if len(val) > 8 {
-			return nil, ErrBadFloatRecord
-		}
-		f := UnzipFloat(val)
-		if math.IsNaN(float64(f)) {
-			return nil, ErrBadFloatRecord
-		}
-		val = ZipFloat(f)
-		norm = WriteTLKV(norm, lit, idbytes, val)
-	case LitInteger:
-		if len(val) > 8 {
-			return nil, ErrBadIntegerRecord
-		}
-		f := UnzipInteger(val)
-		val = ZipInteger(f)
-		norm = WriteTLKV(norm, lit, idbytes, val)
-	case LitReference:
-		if len(val) > 16 { // todo bad sizes
-			return nil, ErrBadReferenceRecord
-		}
-		i := UnzipID(val)
-		val = ZipID(i)
-		norm = WriteTLKV(norm, lit, idbytes, val)
-	case LitString:
-		if !utf8.Valid(val) {
-			return nil, ErrBadStringRecord
-		}
-		norm = WriteTLKV(norm, lit, idbytes, val)
-	case LitTerm:
-		for _, c := range val {
-			if RON64REV[c] == 0xff {
-				return nil, ErrBadTermRecord
-			}
-		}
-		norm = WriteTLKV(norm, lit, idbytes, val)
-	case LitTuple:
-		plit := stack.TopLit()
-		norm = OpenTLV(norm, LitTuple, stack)
-		norm = append(norm, byte(len(idbytes)))
-		norm = append(norm, idbytes...)
-		l := len(norm)
-		norm, err = normalize(norm, val, nil, stack)
-		if err != nil {
-			return nil, err
-		}
-		if plit == LitEuler && l == len(norm) { // ()
-			norm, err = CancelTLV(norm, LitTuple, stack)
-		} else {
-			norm, err = CloseTLV(norm, LitTuple, stack)
-		}
-	case LitLinear:
-		norm = OpenTLV(norm, LitLinear, stack)
-		norm = append(norm, byte(len(idbytes)))
-		norm = append(norm, idbytes...)
-		norm, err = normalize(norm, val, nil, stack)
-		if err != nil {
-			return
-		}
-		norm, err = CloseTLV(norm, LitLinear, stack)
-	case LitEuler:
-		norm = OpenTLV(norm, LitEuler, stack)
-		norm = append(norm, byte(len(idbytes)))
-		norm = append(norm, idbytes...)
-		norm, err = normalize(norm, val, CompareEuler, stack)
-		if err != nil {
-			return
-		}
-		norm, err = CloseTLV(norm, LitEuler, stack)
-	case LitMultix:
-		norm = OpenTLV(norm, LitMultix, stack)
-		norm = append(norm, byte(len(idbytes)))
-		norm = append(norm, idbytes...)
-		norm, err = normalize(norm, val, CompareMultix, stack)
-		if err != nil {
-			return
-		}
-		norm, err = CloseTLV(norm, LitMultix, stack)
+	m := getMergeContext()
+	m.out, err = m.Normalize(m.out[:0], rdx)
+	if err == nil && len(m.out) > 0 {
+		RDX = append(make([]byte, 0, len(m.out)), m.out...)
 	}
+	putMergeContext(m)
 	return
 }
 
@@ -842,67 +655,18 @@ func Flatten(data, rdx []byte) (flat []byte, err error) {
 	return flatten(data, rdx, &stack)
 }
 
-func delve(data Stream, path Iter, z Compare) (found Stream, err error) {
-	it := NewIter(data)
-	i := Less
-	for i < Eq && it.Read() {
-		i = z(&it, &path)
-	}
-	if !it.HasData() || i > Eq {
-		err = ErrRecordNotFound
-		return
-	}
-	if !path.Read() {
-		return it.Record(), nil
-	}
-	return scan(it, path)
-}
-
 var ErrBadPath = errors.New("bad path")
-
-func delveP(data Stream, path Iter) (found Stream, err error) {
-	if path.Lit() != LitInteger {
-		err = ErrBadPath
-		return
-	}
-	it := NewIter(data)
-	n := UnzipInt64(path.Value())
-	for n >= 0 && it.Read() {
-		n--
-	}
-	if !it.HasData() {
-		err = ErrRecordNotFound
-		return
-	}
-	if !path.Read() {
-		return it.Record(), nil
-	} else if !IsPLEX(it.Lit()) {
-		err = ErrRecordNotFound
-		return
-	} else {
-		return scan(it, path)
-	}
-}
-
-func scan(it Iter, path Iter) (found Stream, err error) {
-	switch it.Lit() {
-	case LitTuple:
-		found, err = delveP(it.Value(), path)
-	case LitLinear:
-		found, err = delve(it.Value(), path, CompareLinear)
-	case LitEuler:
-		found, err = delve(it.Value(), path, CompareEuler)
-	case LitMultix:
-		found, err = delve(it.Value(), path, CompareMultix)
-	default:
-		err = ErrRecordNotFound
-	}
-	return
-}
-
 var ErrNoKeyProvided = errors.New("no key provided")
 var ErrNotPLEX = errors.New("not a PLEX container element")
 
+// Pick finds the element of a PLEX container by a key: an Integer index
+// in a Tuple, an Integer live position or a Reference ID in a Linear,
+// a key (or a tuple of the key) in an Euler, a Reference source in a Multix.
+// For an Euler, the entry is returned, e.g. (key value).
+// Note that a Linear Integer key counts live elements only, it is not
+// matched against the elements with CompareLinear as it used to be.
+// Tombstones are never picked: a deleted element is ErrRecordNotFound,
+// same as a missing one.
 func Pick(key, data Stream) (entry Stream, err error) {
 	dit := NewIter(data)
 	kit := NewIter(key)
@@ -912,48 +676,57 @@ func Pick(key, data Stream) (entry Stream, err error) {
 	if !dit.Read() {
 		return nil, ErrBadRecord
 	}
-	var z Compare
-	switch dit.Lit() {
-	case LitTuple:
-		z = nil // fixme
-	case LitLinear:
-		z = CompareLinear
-	case LitEuler:
-		z = CompareEuler
-	case LitMultix:
-		z = CompareMultix
-	default:
+	if !IsPLEX(dit.Lit()) {
 		return nil, ErrNotPLEX
 	}
-	it := NewIter(dit.Value())
-	i := Less
-	if z != nil {
-		for i < Eq && it.Read() {
-			i = z(&it, &kit)
-		}
-	} else {
-		k := kit.Integer()
-		for k >= 0 && it.Read() {
-			k--
-		}
-		if it.HasData() {
-			i = Eq
-		}
-	}
-	if i == Eq {
-		entry = it.Record()
-	} else {
-		err = ErrRecordNotFound
+	it, err := pickIn(dit.Lit(), dit.Value(), &kit)
+	if err != nil {
+		return nil, err
 	}
-	return
+	return it.Record(), nil
 }
 
+// Delve follows a path of keys, see Pick, down into a document. The
+// top-level elements count as a tuple, so the first step is an Integer
+// index. An Euler step finds the entry, e.g. (key value), so the next
+// step may pick its value by index 1. Like Pick, Delve skips tombstones,
+// so a path to a deleted element fails. A failure is a *PathError.
 func Delve(data, path Stream) (entry Stream, err error) {
+	return delve(data, path, false)
+}
+
+// delve follows a path; values resolves map entries to their values
+func delve(data, path Stream, values bool) (entry Stream, err error) {
 	pi := NewIter(path)
 	if !pi.Read() {
+		if pi.HasFailed() {
+			return nil, pi.Error()
+		}
 		return data, nil
 	}
-	return delveP(data, pi)
+	lit, body := byte(LitTuple), []byte(data)
+	var found Iter
+	for n := 0; ; n++ {
+		if !IsPLEX(lit) {
+			err = ErrNotPLEX
+		} else {
+			found, err = pickIn(lit, body, &pi)
+		}
+		if err != nil {
+			return nil, &PathError{Step: n, Key: pi.Record(), Err: err}
+		}
+		if values && lit == LitEuler {
+			found = entryValue(found)
+		}
+		lit, body = found.Lit(), found.Value()
+		if !pi.Read() {
+			break
+		}
+	}
+	if pi.HasFailed() {
+		return nil, pi.Error()
+	}
+	return found.Record(), nil
 }
 
 func DebugIter(it Iter) (lit, header, id, value []byte) {
@@ -6,6 +6,16 @@ import (
 	"testing"
 )
 
+// flatJDR renders the data as Flatten leaves it, tombstones dropped
+func flatJDR(t *testing.T, rdx []byte) string {
+	t.Helper()
+	flat, err := Flatten(nil, rdx)
+	if err != nil {
+		t.Error(err)
+	}
+	return string(RenderJDR(flat, 0))
+}
+
 func TestNormalize(t *testing.T) {
 	cases := [][2]string{
 		//		{"{1 4 2 2 3 3 3}", "{1 2 3 4}"},
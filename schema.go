@@ -0,0 +1,579 @@
+package rdx
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrBadSchema = errors.New("bad schema")
+
+// Schema is a compiled document shape, see CompileSchema.
+type Schema struct {
+	root *schemaType
+}
+
+// ValidationError is a mismatch between a document and a schema.
+// The path is in the ParsePath syntax, so Lookup(doc, Path) finds
+// the offending element, unless that is a tombstone: Lookup skips
+// those, see Pick. The root has an empty path.
+type ValidationError struct {
+	Path string
+	Msg  string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+	return e.Path + ": " + e.Msg
+}
+
+type schemaField struct {
+	key  string
+	step string
+	t    *schemaType
+}
+
+type schemaType struct {
+	lits     string // accepted types, "" for any
+	union    []*schemaType
+	enum     []string
+	fields   []schemaField
+	elem     *schemaType
+	key      *schemaType
+	tuple    []*schemaType
+	min, max *float64
+	optional bool
+	noTombs  bool
+}
+
+var schemaNames = map[string]string{
+	"any":       "",
+	"float":     "F",
+	"integer":   "I",
+	"number":    "IF",
+	"reference": "R",
+	"string":    "S",
+	"term":      "T",
+	"tuple":     "P",
+	"linear":    "L",
+	"euler":     "E",
+	"multix":    "X",
+}
+
+var litNames = map[byte]string{
+	LitFloat:     "float",
+	LitInteger:   "integer",
+	LitReference: "reference",
+	LitString:    "string",
+	LitTerm:      "term",
+	LitTuple:     "tuple",
+	LitLinear:    "linear",
+	LitEuler:     "euler",
+	LitMultix:    "multix",
+}
+
+// CompileSchema compiles a schema written in JDR, e.g.
+// {name:string, age:(integer min:0), tags:[term], meta:<integer>}
+//
+//	string term integer float reference   FIRST types
+//	number any                            integer or float, anything
+//	[T] <T> {T}                           Linear, Multix, Euler set of T
+//	{key:T, ...}                          a record: an Euler of these keys
+//	(map K V)                             an Euler of (K V) entries
+//	(tuple T1 T2 ...)                     a tuple of these elements
+//	(union T1 T2 ...)                     any of the types
+//	(enum a b c)                          one of the values, e.g. Terms
+//	(T min:1 max:9)                       value limits for numbers; length
+//	                                      limits for strings, containers
+//	(T optional)                          a record field may be missing
+//	(T tombstones:false)                  tombstones are not accepted
+//
+// The options go last; for the (map ...), (tuple ...), (union ...)
+// and (enum ...) forms, those follow the members, e.g.
+// (enum red green optional).
+// By default tombstones are accepted and not checked further.
+func CompileSchema(schema Stream) (*Schema, error) {
+	it := NewIter(schema)
+	if !it.Read() {
+		return nil, ErrBadSchema
+	}
+	root, err := compileType(&it)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// schemaKey is the identity of a record key; Terms and Strings
+// are interchangeable there
+func schemaKey(it *Iter) string {
+	if it.Lit() == LitTerm || it.Lit() == LitString {
+		return "S" + string(it.Value())
+	}
+	return string([]byte{it.Lit()}) + string(it.Value())
+}
+
+func compileType(it *Iter) (t *schemaType, err error) {
+	t = &schemaType{}
+	switch it.Lit() {
+	case LitTerm:
+		lits, ok := schemaNames[string(it.Value())]
+		if !ok {
+			return nil, ErrBadSchema
+		}
+		t.lits = lits
+	case LitLinear, LitMultix:
+		t.lits = string([]byte{it.Lit()})
+		in := it.Inner()
+		if in.Read() {
+			if t.elem, err = compileType(&in); err != nil {
+				return nil, err
+			}
+		}
+	case LitEuler:
+		t.lits = "E"
+		err = compileEuler(it, t)
+	case LitTuple:
+		err = compileTuple(it, t)
+	default:
+		err = ErrBadSchema
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func compileEuler(it *Iter, t *schemaType) (err error) {
+	in := it.Inner()
+	for in.Read() {
+		if IsEmptyTuple(&in) {
+			continue
+		}
+		if in.Lit() != LitTuple {
+			if t.fields != nil || t.elem != nil {
+				return ErrBadSchema
+			}
+			t.elem, err = compileType(&in)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if t.elem != nil {
+			return ErrBadSchema
+		}
+		kv := in.Inner()
+		if !kv.Read() {
+			return ErrBadSchema
+		}
+		key := kv
+		if !kv.Read() || kv.HasMore() {
+			return ErrBadSchema
+		}
+		field, err := compileType(&kv)
+		if err != nil {
+			return err
+		}
+		t.fields = append(t.fields, schemaField{
+			key:  schemaKey(&key),
+			step: keyStep(&key),
+			t:    field,
+		})
+	}
+	return nil
+}
+
+func compileTuple(it *Iter, t *schemaType) (err error) {
+	in := it.Inner()
+	if !in.Read() {
+		t.lits = "P" // () is an empty tuple
+		return nil
+	}
+	var rest []*schemaType
+	var args []Iter
+	for more := in; more.Read(); {
+		args = append(args, more)
+	}
+	head := string(in.Value())
+	if in.Lit() == LitTerm {
+		n := len(args)
+		for n > 0 && schemaOption(&args[n-1]) {
+			n--
+		}
+		opts := args[n:]
+		switch head {
+		case "union", "tuple":
+			for i := range args[:n] {
+				el, err := compileType(&args[i])
+				if err != nil {
+					return err
+				}
+				rest = append(rest, el)
+			}
+			if head == "union" {
+				t.union = rest
+			} else {
+				t.lits, t.tuple = "P", rest
+			}
+			return compileOptions(opts, t)
+		case "enum":
+			for _, arg := range args[:n] {
+				if IsPLEX(arg.Lit()) {
+					return ErrBadSchema
+				}
+				t.enum = append(t.enum, string([]byte{arg.Lit()})+string(arg.Value()))
+			}
+			return compileOptions(opts, t)
+		case "map":
+			if n != 2 {
+				return ErrBadSchema
+			}
+			t.lits = "E"
+			if t.key, err = compileType(&args[0]); err != nil {
+				return err
+			}
+			if t.elem, err = compileType(&args[1]); err != nil {
+				return err
+			}
+			return compileOptions(opts, t)
+		}
+	}
+	base, err := compileType(&in)
+	if err != nil {
+		return err
+	}
+	*t = *base
+	return compileOptions(args, t)
+}
+
+// schemaOption tells the options, which go after the members of
+// the union, tuple, enum and map forms
+func schemaOption(arg *Iter) bool {
+	if arg.Lit() == LitTerm {
+		return string(arg.Value()) == "optional"
+	}
+	if arg.Lit() != LitTuple {
+		return false
+	}
+	in := arg.Inner()
+	if !in.Read() || in.Lit() != LitTerm {
+		return false
+	}
+	switch string(in.Value()) {
+	case "min", "max", "tombstones":
+		return true
+	}
+	return false
+}
+
+func compileOptions(args []Iter, t *schemaType) error {
+	for i := range args {
+		if err := compileOption(&args[i], t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compileOption(arg *Iter, t *schemaType) error {
+	if arg.Lit() == LitTerm && string(arg.Value()) == "optional" {
+		t.optional = true
+		return nil
+	}
+	if arg.Lit() != LitTuple {
+		return ErrBadSchema
+	}
+	in := arg.Inner()
+	if !in.Read() || in.Lit() != LitTerm {
+		return ErrBadSchema
+	}
+	name := string(in.Value())
+	if !in.Read() || in.HasMore() {
+		return ErrBadSchema
+	}
+	switch name {
+	case "min", "max":
+		var v float64
+		switch in.Lit() {
+		case LitInteger:
+			v = float64(in.Integer())
+		case LitFloat:
+			v = float64(in.Float())
+		default:
+			return ErrBadSchema
+		}
+		if name == "min" {
+			t.min = &v
+		} else {
+			t.max = &v
+		}
+	case "tombstones":
+		if in.Lit() != LitTerm {
+			return ErrBadSchema
+		}
+		switch string(in.Value()) {
+		case "true":
+			t.noTombs = false
+		case "false":
+			t.noTombs = true
+		default:
+			return ErrBadSchema
+		}
+	default:
+		return ErrBadSchema
+	}
+	return nil
+}
+
+// Validate checks a document (a single root element) against the schema.
+func (s *Schema) Validate(doc Stream) []ValidationError {
+	return s.validate(doc, false)
+}
+
+// ValidatePatch checks a patch, e.g. before a Merge: same as Validate,
+// except missing record fields and () placeholders are fine.
+func (s *Schema) ValidatePatch(patch Stream) []ValidationError {
+	return s.validate(patch, true)
+}
+
+func (s *Schema) validate(doc Stream, patch bool) []ValidationError {
+	v := schemaCheck{patch: patch}
+	it := NewIter(doc)
+	if !it.Read() {
+		v.fail("", "no root element")
+	} else {
+		v.check(&it, s.root, "")
+		if it.HasMore() {
+			v.fail("", "more than one root element")
+		}
+	}
+	if it.HasFailed() {
+		v.fail("", it.Error().Error())
+	}
+	return v.errs
+}
+
+type schemaCheck struct {
+	patch bool
+	errs  []ValidationError
+}
+
+func (v *schemaCheck) fail(path, msg string) {
+	v.errs = append(v.errs, ValidationError{Path: path, Msg: msg})
+}
+
+// keyStep renders a map key as a path step, see ParsePath
+func keyStep(key *Iter) string {
+	val := string(key.Value())
+	if key.Lit() == LitTerm && strings.IndexAny(val, ".:{[<*") < 0 {
+		if _, err := strconv.ParseInt(val, 10, 64); err != nil {
+			return "." + val
+		}
+	}
+	return elemStep(key)
+}
+
+// elemStep renders a set element as a path step
+func elemStep(el *Iter) string {
+	flat, _ := Flatten(nil, el.Record())
+	return "{" + strings.TrimSpace(string(RenderJDR(flat, 0))) + "}"
+}
+
+func join(path, step string) string {
+	if path == "" && step[0] == '.' {
+		return step[1:]
+	}
+	return path + step
+}
+
+func (v *schemaCheck) check(it *Iter, t *schemaType, path string) {
+	if !it.IsLive() {
+		if t.noTombs {
+			v.fail(path, "tombstones are not allowed")
+		}
+		return
+	}
+	if v.patch && IsEmptyTuple(it) {
+		return // a placeholder
+	}
+	lit := it.Lit()
+	if t.union != nil {
+		matched := false
+		for _, alt := range t.union {
+			try := schemaCheck{patch: v.patch}
+			try.check(it, alt, path)
+			if matched = len(try.errs) == 0; matched {
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "matches no type of the union")
+		} else {
+			v.limits(it, t, path)
+		}
+		return
+	}
+	if t.enum != nil {
+		val := string([]byte{lit}) + string(it.Value())
+		if !slices.Contains(t.enum, val) {
+			v.fail(path, "not in the enum")
+		} else {
+			v.limits(it, t, path)
+		}
+		return
+	}
+	if t.lits != "" && strings.IndexByte(t.lits, lit) < 0 {
+		want := make([]string, 0, len(t.lits))
+		for i := 0; i < len(t.lits); i++ {
+			want = append(want, litNames[t.lits[i]])
+		}
+		v.fail(path, "want "+strings.Join(want, " or ")+", have "+litNames[lit])
+		return
+	}
+	v.limits(it, t, path)
+	switch lit {
+	case LitLinear:
+		in := it.Inner()
+		for n := 0; in.Read(); {
+			step := "[" + strconv.Itoa(n) + "]"
+			if in.IsLive() {
+				n++
+			} else {
+				step = "[" + string(in.ID().RonString()) + "]"
+			}
+			if t.elem != nil {
+				v.check(&in, t.elem, path+step)
+			}
+		}
+	case LitMultix:
+		in := it.Inner()
+		for in.Read() {
+			if t.elem != nil {
+				step := "<" + string(RON64String(in.ID().Src)) + ">"
+				v.check(&in, t.elem, path+step)
+			}
+		}
+	case LitTuple:
+		if t.tuple != nil {
+			v.checkTuple(it, t, path)
+		}
+	case LitEuler:
+		v.checkEuler(it, t, path)
+	}
+}
+
+func (v *schemaCheck) limits(it *Iter, t *schemaType, path string) {
+	if t.min == nil && t.max == nil {
+		return
+	}
+	var val float64
+	what := "value"
+	switch it.Lit() {
+	case LitInteger:
+		val = float64(it.Integer())
+	case LitFloat:
+		val = float64(it.Float())
+	case LitString, LitTerm:
+		val = float64(utf8.RuneCount(it.Value()))
+		what = "length"
+	case LitReference:
+		return
+	default:
+		in := it.Inner()
+		for in.Read() {
+			if in.IsLive() {
+				val++
+			}
+		}
+		what = "length"
+	}
+	if t.min != nil && val < *t.min {
+		v.fail(path, what+" below the minimum "+strconv.FormatFloat(*t.min, 'g', -1, 64))
+	}
+	if t.max != nil && val > *t.max {
+		v.fail(path, what+" above the maximum "+strconv.FormatFloat(*t.max, 'g', -1, 64))
+	}
+}
+
+func (v *schemaCheck) checkTuple(it *Iter, t *schemaType, path string) {
+	in := it.Inner()
+	n := 0
+	for ; in.Read(); n++ {
+		step := "[" + strconv.Itoa(n) + "]"
+		if n >= len(t.tuple) {
+			v.fail(path+step, "extra tuple element")
+			return
+		}
+		v.check(&in, t.tuple[n], path+step)
+	}
+	for ; n < len(t.tuple) && !v.patch; n++ {
+		if !t.tuple[n].optional {
+			v.fail(path+"["+strconv.Itoa(n)+"]", "missing tuple element")
+		}
+	}
+}
+
+func (v *schemaCheck) checkEuler(it *Iter, t *schemaType, path string) {
+	seen := make(map[string]bool)
+	in := it.Inner()
+	for in.Read() {
+		if IsEmptyTuple(&in) {
+			continue
+		}
+		if t.fields == nil && t.key == nil {
+			if t.elem != nil {
+				v.check(&in, t.elem, path+elemStep(&in))
+			}
+			continue
+		}
+		kv := in.Inner()
+		if in.Lit() != LitTuple || !kv.Read() {
+			v.fail(path+elemStep(&in), "not a map entry")
+			continue
+		}
+		key := kv
+		step := join(path, keyStep(&key))
+		if !in.IsLive() {
+			v.check(&in, &schemaType{noTombs: t.noTombs}, step)
+			continue
+		}
+		if !kv.Read() || kv.HasMore() {
+			v.fail(step, "not a (key value) entry")
+			continue
+		}
+		if t.key != nil {
+			v.check(&key, t.key, step)
+			v.check(&kv, t.elem, step)
+			continue
+		}
+		field := t.field(schemaKey(&key))
+		if field == nil {
+			v.fail(step, "unexpected key")
+			continue
+		}
+		seen[field.key] = true
+		v.check(&kv, field.t, step)
+	}
+	if v.patch {
+		return
+	}
+	for _, field := range t.fields {
+		if !field.t.optional && !seen[field.key] {
+			v.fail(join(path, field.step), "missing field")
+		}
+	}
+}
+
+func (t *schemaType) field(key string) *schemaField {
+	for i := range t.fields {
+		if t.fields[i].key == key {
+			return &t.fields[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	src, err := ParseJDR([]byte(`{
+		name: (string min:1 max:8),
+		age: (integer min:0 max:150),
+		tags: [term],
+		meta: <integer>,
+		role: (enum admin user),
+		id: (union string integer),
+		nick: (string optional),
+		pets: (map string (tuple term number)),
+		log: ([(string tombstones:false)] optional),
+		mood: (enum happy sad optional),
+		code: (union string integer max:5 optional),
+	}`))
+	assert.Nil(t, err)
+	schema, err := CompileSchema(src)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		doc  string
+		errs []string
+	}{
+		{`{name:"Alice", age:33, tags:[a b], meta:<1@alice-2>, role:admin, id:7,
+			pets:{"Rex":(dog 3.5)}, mood:sad, code:"abc"}`, nil},
+		{`{name:"Alexander the Great", age:-1, tags:[a "b"], meta:<x@bob-2>,
+			role:root, id:1.5, pets:{"Rex":(dog)}, mood:angry, code:"abcdef"}`, []string{
+			`name: length above the maximum 8`,
+			`age: value below the minimum 0`,
+			`tags[1]: want term, have string`,
+			`meta<bob>: want integer, have term`,
+			`role: not in the enum`,
+			`id: matches no type of the union`,
+			`pets{"Rex"}[1]: missing tuple element`,
+			`mood: not in the enum`,
+			`code: length above the maximum 5`,
+		}},
+		{`{name:"Bob", extra:1}`, []string{
+			`extra: unexpected key`,
+			`age: missing field`,
+			`tags: missing field`,
+			`meta: missing field`,
+			`role: missing field`,
+			`id: missing field`,
+			`pets: missing field`,
+		}},
+		{`[1 2]`, []string{`want euler, have linear`}},
+	}
+	for _, c := range cases {
+		doc, err := ParseNormalizeJDR([]byte(c.doc))
+		assert.Nil(t, err, c.doc)
+		var errs []string
+		for _, e := range schema.Validate(doc) {
+			errs = append(errs, e.Error())
+		}
+		assert.ElementsMatch(t, c.errs, errs, c.doc)
+	}
+
+	doc, _ := ParseNormalizeJDR([]byte(`{name:"Bob", age:5, log:["a"@bob-20 "b"@bob-31]}`))
+	errs := schema.ValidatePatch(doc)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, `log[bob-31]`, errs[0].Path)
+	_, err = Lookup(doc, errs[0].Path)
+	assert.ErrorIs(t, err, ErrRecordNotFound) // a tombstone
+
+	_, err = CompileSchema(jdrOf(t, `{name:strin}`))
+	assert.Equal(t, ErrBadSchema, err)
+	_, err = CompileSchema(jdrOf(t, `(integer min:x)`))
+	assert.Equal(t, ErrBadSchema, err)
+	_, err = CompileSchema(jdrOf(t, `(map string integer term)`))
+	assert.Equal(t, ErrBadSchema, err)
+}
+
+func jdrOf(t *testing.T, jdr string) Stream {
+	rdx, err := ParseJDR([]byte(jdr))
+	assert.Nil(t, err)
+	return rdx
+}
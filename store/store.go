@@ -0,0 +1,450 @@
+// Package store is an embedded replicated document store: a map of
+// object IDs to RDX documents. Patches, local or coming from other
+// replicas, go to a checksummed write-ahead log first, then get merged
+// into the documents kept in memory. Once enough log segments pile up,
+// those get compacted into a sorted snapshot, an rdx table file. After
+// a crash, Open loads the snapshot and replays the log, cutting off
+// an entry torn by the crash.
+//
+// The directory holds:
+//
+//	0000000000000007.wal    log segments, numbered
+//	0000000000000005.tbl    the snapshot of all the segments up to #5
+//	*.tmp                   a snapshot being written
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gritzko/rdx"
+)
+
+const (
+	DefaultSegmentSize = 1 << 22
+	DefaultSegments    = 4
+)
+
+var (
+	ErrCorrupt = errors.New("corrupt log segment")
+	ErrClosed  = errors.New("store is closed")
+)
+
+// Store keeps RDX documents by their IDs, revisions ignored. It is safe
+// for concurrent use.
+type Store struct {
+	// SegmentSize is the size to start a new log segment at
+	SegmentSize int64
+	// Segments is the number of full segments to compact at
+	Segments int
+	// Sync makes Apply flush the log to the disk before it returns
+	Sync bool
+
+	mu   sync.Mutex
+	dir  string
+	docs map[rdx.ID]rdx.Stream
+	snap uint64 // the last segment in the snapshot, 0 if none
+	seg  uint64 // the segment being written
+	wal  *os.File
+	size int64
+	buf  []byte
+	err  error
+}
+
+var _ rdx.Getter = (*Store)(nil)
+
+func walName(n uint64) string {
+	return fmt.Sprintf("%016x.wal", n)
+}
+
+func tblName(n uint64) string {
+	return fmt.Sprintf("%016x.tbl", n)
+}
+
+// Open opens the store in the directory, creating it if needed, and
+// recovers the documents from the snapshot and the log.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		SegmentSize: DefaultSegmentSize,
+		Segments:    DefaultSegments,
+		Sync:        true,
+		dir:         dir,
+		docs:        make(map[rdx.ID]rdx.Stream),
+	}
+	if err := s.recover(); err != nil {
+		if s.wal != nil {
+			_ = s.wal.Close()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *Store) recover() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var segs, snaps []uint64
+	for _, e := range entries {
+		name := e.Name()
+		var n uint64
+		switch {
+		case strings.HasSuffix(name, ".tmp"):
+			// a compaction did not finish
+			if err = os.Remove(s.path(name)); err != nil {
+				return err
+			}
+		case strings.HasSuffix(name, ".wal"):
+			if _, err := fmt.Sscanf(name, "%016x.wal", &n); err == nil {
+				segs = append(segs, n)
+			}
+		case strings.HasSuffix(name, ".tbl"):
+			if _, err := fmt.Sscanf(name, "%016x.tbl", &n); err == nil {
+				snaps = append(snaps, n)
+			}
+		}
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i] < snaps[j] })
+	if len(snaps) > 0 {
+		s.snap = snaps[len(snaps)-1]
+		if err = s.loadSnapshot(); err != nil {
+			return err
+		}
+	}
+	if err = s.cleanup(); err != nil {
+		return err
+	}
+	s.seg = s.snap + 1
+	for i, n := range segs {
+		if n <= s.snap {
+			continue
+		}
+		s.seg = n
+		valid, torn, err := readSegment(s.path(walName(n)), s.merge)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(s.path(walName(n)))
+		if err != nil {
+			return err
+		}
+		if valid < info.Size() {
+			if !torn || i+1 < len(segs) {
+				return ErrCorrupt
+			}
+			if err = os.Truncate(s.path(walName(n)), valid); err != nil {
+				return err
+			}
+		}
+		s.size = valid
+	}
+	s.wal, err = os.OpenFile(s.path(walName(s.seg)), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+func (s *Store) loadSnapshot() error {
+	r, err := rdx.OpenTableReader(s.path(tblName(s.snap)))
+	if err != nil {
+		return err
+	}
+	for r.Read() {
+		rec := r.Record()
+		_, id, _ := r.Parsed()
+		s.docs[id.Base()] = append(rdx.Stream(nil), rec...)
+	}
+	err = r.Error()
+	if e := r.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// cleanup removes the files the snapshot makes obsolete
+func (s *Store) cleanup() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var n uint64
+		name := e.Name()
+		_, ewal := fmt.Sscanf(name, "%016x.wal", &n)
+		if (ewal == nil && n <= s.snap) || (name != tblName(s.snap) && strings.HasSuffix(name, ".tbl")) {
+			if err = os.Remove(s.path(name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// merged merges a patch into copies of the documents it touches,
+// leaving the documents in memory as they are
+func (s *Store) merged(patch rdx.Stream) (map[rdx.ID]rdx.Stream, error) {
+	docs := make(map[rdx.ID]rdx.Stream)
+	it := rdx.NewIter(patch)
+	for it.Read() {
+		key := it.ID().Base()
+		doc, ok := docs[key]
+		if !ok {
+			doc = s.docs[key]
+		}
+		doc, err := rdx.Merge(nil, [][]byte{doc, it.Record()})
+		if err != nil {
+			return nil, err
+		}
+		docs[key] = doc
+	}
+	return docs, it.Error()
+}
+
+// merge merges a patch into the documents in memory, all or nothing
+func (s *Store) merge(patch rdx.Stream) error {
+	docs, err := s.merged(patch)
+	if err != nil {
+		return err
+	}
+	for key, doc := range docs {
+		s.docs[key] = doc
+	}
+	return nil
+}
+
+// Apply merges a patch into the documents, logging it first; the
+// top-level records of the patch go to the documents of their IDs. The
+// patch must be normalized, see rdx.Validate. A patch that fails to
+// merge is neither logged nor applied. An error of the log stops the
+// store.
+func (s *Store) Apply(patch rdx.Stream) error {
+	if err := rdx.Validate(patch); err != nil {
+		return err
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	docs, err := s.merged(patch)
+	if err != nil {
+		return err
+	}
+	s.buf = appendEntry(s.buf[:0], patch)
+	if _, err := s.wal.Write(s.buf); err != nil {
+		s.err = err
+		return err
+	}
+	if s.Sync {
+		if err := s.wal.Sync(); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	s.size += int64(len(s.buf))
+	for key, doc := range docs {
+		s.docs[key] = doc
+	}
+	if s.size < s.SegmentSize {
+		return nil
+	}
+	if err := s.roll(); err != nil {
+		s.err = err
+		return err
+	}
+	if s.seg-1-s.snap >= uint64(max(s.Segments, 1)) {
+		if err := s.compact(s.seg - 1); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// roll starts a new log segment
+func (s *Store) roll() error {
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	if err := s.wal.Close(); err != nil {
+		return err
+	}
+	wal, err := os.OpenFile(s.path(walName(s.seg+1)), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.wal, s.seg, s.size = wal, s.seg+1, 0
+	return syncDir(s.dir)
+}
+
+// Compact compacts the whole log into a new snapshot.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.size > 0 {
+		if err := s.roll(); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	if s.seg-1 == s.snap {
+		return nil
+	}
+	if err := s.compact(s.seg - 1); err != nil {
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+// compact merges the snapshot and the segments up to #last into the
+// next snapshot; every patch gets sorted by ID, then all of those get
+// merged with HeapMerge
+func (s *Store) compact(last uint64) error {
+	var inputs [][]byte
+	if s.snap > 0 {
+		r, err := rdx.OpenTableReader(s.path(tblName(s.snap)))
+		if err != nil {
+			return err
+		}
+		var old []byte
+		for r.Read() {
+			old = append(old, r.Record()...)
+		}
+		err = r.Error()
+		if e := r.Close(); err == nil {
+			err = e
+		}
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, old)
+	}
+	for n := s.snap + 1; n <= last; n++ {
+		valid, _, err := readSegment(s.path(walName(n)), func(patch rdx.Stream) error {
+			sorted, err := sortByID(patch)
+			inputs = append(inputs, sorted)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(s.path(walName(n))); err != nil {
+			return err
+		} else if valid < info.Size() {
+			return ErrCorrupt
+		}
+	}
+	merged, err := rdx.HeapMerge(nil, inputs, rdx.CompareID)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(tblName(last) + ".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := rdx.NewTableWriter(file)
+	err = w.Append(merged)
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path(tblName(last)))
+	}
+	if err == nil {
+		err = syncDir(s.dir)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	s.snap = last
+	return s.cleanup()
+}
+
+// sortByID orders the records of a patch by ID, merging the same-ID ones
+func sortByID(patch rdx.Stream) ([]byte, error) {
+	var records [][]byte
+	it := rdx.NewIter(patch)
+	for it.Read() {
+		records = append(records, it.Record())
+	}
+	if it.HasFailed() {
+		return nil, it.Error()
+	}
+	return rdx.HeapMerge(nil, records, rdx.CompareID)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if e := d.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Get returns a copy of the document with the ID, revisions ignored;
+// rdx.ErrRecordNotFound if there is none.
+func (s *Store) Get(id rdx.ID) (value rdx.Stream, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.docs[id.Base()]
+	if !ok {
+		return nil, rdx.ErrRecordNotFound
+	}
+	return append(rdx.Stream(nil), doc...), nil
+}
+
+// Len returns the number of documents.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.docs)
+}
+
+// Close flushes the log and closes the store.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == ErrClosed {
+		return ErrClosed
+	}
+	err := s.wal.Sync()
+	if e := s.wal.Close(); err == nil {
+		err = e
+	}
+	s.err = ErrClosed
+	return err
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gritzko/rdx"
+	"github.com/stretchr/testify/assert"
+)
+
+func jdrOf(t *testing.T, jdr string) rdx.Stream {
+	data, err := rdx.ParseJDR([]byte(jdr))
+	assert.Nil(t, err)
+	return data
+}
+
+func getJDR(t *testing.T, s *Store, id string) string {
+	oid, err := rdx.NewID([]byte(id))
+	assert.Nil(t, err)
+	doc, err := s.Get(oid)
+	if err != nil {
+		return err.Error()
+	}
+	return string(rdx.RenderJDR(doc, 0))
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Apply(jdrOf(t, `{@alice-10 a:1, b:2} {@bob-10 x:"x"}`)))
+	assert.Nil(t, s.Apply(jdrOf(t, `{@alice-10 a:3@bob-4}`)))
+	assert.Nil(t, s.Apply(jdrOf(t, `{@bob-11}`)))
+	assert.Equal(t, `{@alice-10 (a 3@bob-4) (b 2)}`, getJDR(t, s, "alice-10"))
+	assert.Equal(t, `{@alice-10 (a 3@bob-4) (b 2)}`, getJDR(t, s, "alice-12"))
+	assert.Equal(t, `{@bob-11 (x "x")}`, getJDR(t, s, "bob-10"))
+	assert.Equal(t, rdx.ErrRecordNotFound.Error(), getJDR(t, s, "carol-10"))
+	assert.Equal(t, 2, s.Len())
+
+	bad := append(rdx.Stream{}, jdrOf(t, `{b:1 a:2}`)...)
+	assert.NotNil(t, s.Apply(bad[:len(bad)-1]))
+	assert.Nil(t, s.Close())
+	assert.Equal(t, ErrClosed, s.Apply(jdrOf(t, `1`)))
+
+	s, err = Open(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, `{@alice-10 (a 3@bob-4) (b 2)}`, getJDR(t, s, "alice-10"))
+	assert.Equal(t, `{@bob-11 (x "x")}`, getJDR(t, s, "bob-10"))
+	assert.Nil(t, s.Close())
+}
+
+func TestStoreCompaction(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	assert.Nil(t, err)
+	s.SegmentSize = 256
+	s.Segments = 2
+	s.Sync = false
+	want := map[string]rdx.Stream{}
+	ids := []string{"alice-10", "bob-20", "carol-30", "alice-40"}
+	for i := 0; i < 200; i++ {
+		id := ids[i%len(ids)]
+		patch := jdrOf(t, fmt.Sprintf("{@%s %c:%d@dave-%d}", id, 'a'+i%7, i, 2+2*(i/40)))
+		assert.Nil(t, s.Apply(patch))
+		merged, err := rdx.Merge(nil, [][]byte{want[id], patch})
+		assert.Nil(t, err)
+		want[id] = merged
+	}
+	assert.Greater(t, s.snap, uint64(0))
+	check := func() {
+		for _, id := range ids {
+			assert.Equal(t, string(rdx.RenderJDR(want[id], 0)), getJDR(t, s, id))
+		}
+	}
+	check()
+	assert.Nil(t, s.Compact())
+	check()
+	assert.Nil(t, s.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(files)) // the snapshot and an empty segment
+
+	s, err = Open(dir)
+	assert.Nil(t, err)
+	check()
+	assert.Nil(t, s.Close())
+}
+
+func TestStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Apply(jdrOf(t, `{@alice-10 a:1}`)))
+	assert.Nil(t, s.Compact())
+	assert.Nil(t, s.Apply(jdrOf(t, `{@alice-10 b:2}`)))
+	assert.Nil(t, s.Apply(jdrOf(t, `{@alice-10 c:3}`)))
+	last := s.path(walName(s.seg))
+	assert.Nil(t, s.Close())
+	info, err := os.Stat(last)
+	assert.Nil(t, err)
+	const want = `{@alice-10 (a 1) (b 2) (c 3)}`
+
+	// a crash mid-write leaves a torn entry
+	torn := appendEntry(nil, jdrOf(t, `{@alice-10 d:4}`))
+	for _, tail := range [][]byte{
+		torn[:5],
+		torn[:len(torn)-2],
+		append(torn[:len(torn)-1:len(torn)-1], 'x'),
+		make([]byte, 100),
+	} {
+		file, err := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0)
+		assert.Nil(t, err)
+		_, err = file.Write(tail)
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+		// and a compaction that did not finish
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, tblName(9)+".tmp"), tail, 0o644))
+
+		s, err = Open(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, want, getJDR(t, s, "alice-10"))
+		assert.Nil(t, s.Close())
+		now, err := os.Stat(last)
+		assert.Nil(t, err)
+		assert.Equal(t, info.Size(), now.Size())
+		_, err = os.Stat(filepath.Join(dir, tblName(9)+".tmp"))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// the log is good again
+	s, err = Open(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Apply(jdrOf(t, `{@alice-10 d:4}`)))
+	assert.Nil(t, s.Close())
+	s, err = Open(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, `{@alice-10 (a 1) (b 2) (c 3) (d 4)}`, getJDR(t, s, "alice-10"))
+	assert.Nil(t, s.Close())
+
+	// a bad entry followed by good ones is not a crash
+	data, err := os.ReadFile(last)
+	assert.Nil(t, err)
+	data[walHeaderLen+2] ^= 0xff
+	assert.Nil(t, os.WriteFile(last, data, 0o644))
+	_, err = Open(dir)
+	assert.Equal(t, ErrCorrupt, err)
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+
+	"github.com/gritzko/rdx"
+)
+
+// Log segment layout: a sequence of entries, one per patch,
+//
+//	length u32, crc32c u32 of the patch, patch
+//
+// little-endian. A crash may leave a torn entry at the tail of the
+// last segment: cut short, mismatching its checksum or zero-filled.
+// Recovery cuts the tail off; a bad entry anywhere else is corruption.
+
+const walHeaderLen = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func appendEntry(data []byte, patch rdx.Stream) []byte {
+	var hdr [walHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(patch)))
+	binary.LittleEndian.PutUint32(hdr[4:8], crc32.Checksum(patch, crcTable))
+	data = append(data, hdr[:]...)
+	return append(data, patch...)
+}
+
+// readSegment calls fn for every entry of a segment; valid is the length
+// of the good part, torn tells there is a torn tail past it
+func readSegment(path string, fn func(patch rdx.Stream) error) (valid int64, torn bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+	off := 0
+	for off < len(data) {
+		rest := data[off:]
+		if len(rest) < walHeaderLen {
+			return int64(off), true, nil
+		}
+		n := int(binary.LittleEndian.Uint32(rest[0:4]))
+		sum := binary.LittleEndian.Uint32(rest[4:8])
+		if n == 0 || n > len(rest)-walHeaderLen {
+			return int64(off), n > len(rest)-walHeaderLen || isZero(rest), nil
+		}
+		patch := rest[walHeaderLen : walHeaderLen+n]
+		if crc32.Checksum(patch, crcTable) != sum {
+			end := walHeaderLen+n == len(rest)
+			return int64(off), end || isZero(rest), nil
+		}
+		if err = fn(patch); err != nil {
+			return int64(off), false, err
+		}
+		off += walHeaderLen + n
+	}
+	return int64(off), false, nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
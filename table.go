@@ -0,0 +1,389 @@
+package rdx
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// Table file layout:
+//
+//	block...   records ordered by CompareID, ~BlockSize bytes per block
+//	index      one (first-ID offset length sha256-hex) tuple per block
+//	bloom      bloom filter bits on the record IDs, may be empty
+//	trailer    index offset u64, index length u32, bloom length u32,
+//	           bloom hash count u32, magic "RDXT" (little-endian)
+//
+// IDs are compared without the revision bits, so a table holds one
+// version of every element. The trailer is fixed size, so a reader
+// finds the index by reading the tail of the file.
+
+const (
+	TableBlockSize    = 1 << 12
+	TableBloomBits    = 10
+	tableTrailerLen   = 24
+	tableMagic        = "RDXT"
+	tableBloomHashMax = 16
+)
+
+var (
+	ErrTableOrder    = errors.New("table records must be in ascending ID order")
+	ErrTableChecksum = errors.New("table block checksum mismatch")
+	ErrTableClosed   = errors.New("table writer is closed")
+)
+
+type tableBlock struct {
+	first ID
+	off   int64
+	len   int
+	sha   Sha256
+}
+
+func bloomHash(id ID) (h1, h2 uint64) {
+	x := id.Src*0x9e3779b97f4a7c15 ^ (id.Seq & MaskNoRev)
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x, (x >> 32) | (x << 32) | 1
+}
+
+// TableWriter writes a table file; records must come in strictly
+// ascending CompareID order.
+type TableWriter struct {
+	w         io.Writer
+	off       int64
+	block     []byte
+	first     ID
+	last      ID
+	n         int
+	index     []byte
+	keys      []uint64
+	BlockSize int
+	BloomBits int
+	err       error
+}
+
+func NewTableWriter(w io.Writer) *TableWriter {
+	return &TableWriter{
+		w:         w,
+		BlockSize: TableBlockSize,
+		BloomBits: TableBloomBits,
+	}
+}
+
+// Append adds one or more records to the table.
+func (t *TableWriter) Append(rdx Stream) error {
+	it := NewIter(rdx)
+	for t.err == nil && it.Read() {
+		id := it.ID()
+		if t.n > 0 && t.last.Compare(id) >= Eq {
+			t.err = ErrTableOrder
+			break
+		}
+		if len(t.block) == 0 {
+			t.first = id
+		}
+		t.block = append(t.block, it.Record()...)
+		t.last = id
+		t.n++
+		h1, h2 := bloomHash(id)
+		t.keys = append(t.keys, h1, h2)
+		if len(t.block) >= t.BlockSize {
+			t.flushBlock()
+		}
+	}
+	if t.err == nil && it.HasFailed() {
+		t.err = it.Error()
+	}
+	return t.err
+}
+
+func (t *TableWriter) write(data []byte) {
+	if t.err != nil {
+		return
+	}
+	var n int
+	n, t.err = t.w.Write(data)
+	t.off += int64(n)
+}
+
+func (t *TableWriter) flushBlock() {
+	if len(t.block) == 0 {
+		return
+	}
+	sha := Sha256Of(t.block)
+	entry := R0(t.first)
+	entry = append(entry, I0(Integer(t.off))...)
+	entry = append(entry, I0(Integer(len(t.block)))...)
+	entry = WriteRDX(entry, LitString, ID0, []byte(sha.String()))
+	t.index = WriteRDX(t.index, LitTuple, ID0, entry)
+	t.write(t.block)
+	t.block = t.block[:0]
+}
+
+// Close flushes the last block and writes the index, the bloom filter
+// and the trailer. Does not close the underlying writer.
+func (t *TableWriter) Close() error {
+	if t.err != nil {
+		return t.err
+	}
+	t.flushBlock()
+	indexOff := t.off
+	t.write(t.index)
+	var bloom []byte
+	hashes := 0
+	if t.BloomBits > 0 && t.n > 0 {
+		bits := uint64(t.n*t.BloomBits+7) &^ 7
+		bloom = make([]byte, bits/8)
+		hashes = t.BloomBits * 69 / 100
+		hashes = max(1, min(hashes, tableBloomHashMax))
+		for k := 0; k < len(t.keys); k += 2 {
+			h1, h2 := t.keys[k], t.keys[k+1]
+			for i := 0; i < hashes; i++ {
+				bit := (h1 + uint64(i)*h2) % bits
+				bloom[bit>>3] |= 1 << (bit & 7)
+			}
+		}
+		t.write(bloom)
+	}
+	var trailer [tableTrailerLen]byte
+	binary.LittleEndian.PutUint64(trailer[0:8], uint64(indexOff))
+	binary.LittleEndian.PutUint32(trailer[8:12], uint32(len(t.index)))
+	binary.LittleEndian.PutUint32(trailer[12:16], uint32(len(bloom)))
+	binary.LittleEndian.PutUint32(trailer[16:20], uint32(hashes))
+	copy(trailer[20:], tableMagic)
+	t.write(trailer[:])
+	if t.err == nil {
+		t.err = ErrTableClosed
+		return nil
+	}
+	return t.err
+}
+
+// TableReader reads a table file. Seek and Get take O(log n) index
+// lookups plus one block read; the block checksum is verified on every
+// block load.
+type TableReader struct {
+	file   io.ReaderAt
+	closer io.Closer
+	blocks []tableBlock
+	bloom  []byte
+	hashes int
+	bn     int
+	block  []byte
+	it     Iter
+	err    error
+}
+
+var _ ReadSeekCloser = (*TableReader)(nil)
+var _ Getter = (*TableReader)(nil)
+
+func OpenTableReader(path string) (*TableReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	t, err := NewTableReader(file, stat.Size())
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	t.closer = file
+	return t, nil
+}
+
+func NewTableReader(file io.ReaderAt, size int64) (t *TableReader, err error) {
+	var trailer [tableTrailerLen]byte
+	if size < tableTrailerLen {
+		return nil, ErrBadHeader
+	}
+	if _, err = file.ReadAt(trailer[:], size-tableTrailerLen); err != nil {
+		return nil, err
+	}
+	if string(trailer[20:]) != tableMagic {
+		return nil, ErrBadHeader
+	}
+	indexOff := int64(binary.LittleEndian.Uint64(trailer[0:8]))
+	indexLen := int64(binary.LittleEndian.Uint32(trailer[8:12]))
+	bloomLen := int64(binary.LittleEndian.Uint32(trailer[12:16]))
+	if indexOff < 0 || indexOff+indexLen+bloomLen+tableTrailerLen != size {
+		return nil, ErrBadHeader
+	}
+	hashes := binary.LittleEndian.Uint32(trailer[16:20])
+	if hashes > tableBloomHashMax || (hashes == 0) != (bloomLen == 0) {
+		return nil, ErrBadHeader
+	}
+	t = &TableReader{
+		file:   file,
+		hashes: int(hashes),
+		bn:     -1,
+	}
+	tail := make([]byte, indexLen+bloomLen)
+	if _, err = file.ReadAt(tail, indexOff); err != nil {
+		return nil, err
+	}
+	t.bloom = tail[indexLen:]
+	it := NewIter(tail[:indexLen])
+	for it.Read() {
+		var b tableBlock
+		in := it.Inner()
+		if !in.Read() || in.Lit() != LitReference {
+			return nil, ErrBadHeader
+		}
+		b.first = in.Reference()
+		if !in.Read() || in.Lit() != LitInteger {
+			return nil, ErrBadHeader
+		}
+		b.off = int64(in.Integer())
+		if !in.Read() || in.Lit() != LitInteger {
+			return nil, ErrBadHeader
+		}
+		b.len = int(in.Integer())
+		if !in.Read() || in.Lit() != LitString ||
+			b.off < 0 || b.len < 0 || b.off+int64(b.len) > indexOff {
+			return nil, ErrBadHeader
+		}
+		if b.sha, err = ParseSha256(in.Value()); err != nil {
+			return nil, ErrBadHeader
+		}
+		t.blocks = append(t.blocks, b)
+	}
+	if it.HasFailed() {
+		return nil, it.Error()
+	}
+	return t, nil
+}
+
+// Len returns the number of blocks.
+func (t *TableReader) Len() int {
+	return len(t.blocks)
+}
+
+func (t *TableReader) load(bn int) bool {
+	if bn >= len(t.blocks) {
+		t.it = Iter{}
+		t.bn = len(t.blocks)
+		return false
+	}
+	b := &t.blocks[bn]
+	if cap(t.block) < b.len {
+		t.block = make([]byte, b.len)
+	}
+	t.block = t.block[:b.len]
+	if _, err := t.file.ReadAt(t.block, b.off); err != nil {
+		t.err = err
+		return false
+	}
+	if Sha256Of(t.block) != b.sha {
+		t.err = ErrTableChecksum
+		return false
+	}
+	t.bn = bn
+	t.it = NewIter(t.block)
+	return true
+}
+
+func (t *TableReader) Read() bool {
+	if t.err != nil {
+		return false
+	}
+	if t.bn < 0 && !t.load(0) {
+		return false
+	}
+	for !t.it.Read() {
+		if t.it.HasFailed() {
+			t.err = t.it.Error()
+			return false
+		}
+		if !t.load(t.bn + 1) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *TableReader) Record() Stream {
+	if !t.it.HasData() {
+		return nil
+	}
+	return t.it.Record()
+}
+
+func (t *TableReader) Parsed() (lit byte, id ID, value []byte) {
+	return t.it.Parsed()
+}
+
+func (t *TableReader) Error() error {
+	return t.err
+}
+
+// Seek moves to the first record with an equal-or-greater ID;
+// returns Eq or Grtr; if none found, returns Less.
+func (t *TableReader) Seek(id ID) int {
+	if t.err != nil || len(t.blocks) == 0 {
+		return Less
+	}
+	bn := sort.Search(len(t.blocks), func(i int) bool {
+		return t.blocks[i].first.Compare(id) > Eq
+	}) - 1
+	if bn < 0 {
+		bn = 0
+	}
+	if bn != t.bn && !t.load(bn) {
+		return Less
+	}
+	t.it = NewIter(t.block)
+	for t.Read() {
+		if z := t.it.ID().Compare(id); z >= Eq {
+			return z
+		}
+	}
+	return Less
+}
+
+func (t *TableReader) mayHave(id ID) bool {
+	if len(t.bloom) == 0 || t.hashes == 0 {
+		return true
+	}
+	bits := uint64(len(t.bloom)) * 8
+	h1, h2 := bloomHash(id)
+	for i := 0; i < t.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % bits
+		if t.bloom[bit>>3]&(1<<(bit&7)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns a copy of the record with the ID, revisions ignored.
+func (t *TableReader) Get(id ID) (value Stream, err error) {
+	if !t.mayHave(id) {
+		return nil, ErrRecordNotFound
+	}
+	if t.Seek(id) != Eq {
+		if t.err != nil {
+			return nil, t.err
+		}
+		return nil, ErrRecordNotFound
+	}
+	return append(Stream(nil), t.it.Record()...), nil
+}
+
+func (t *TableReader) Close() error {
+	t.it = Iter{}
+	t.block = nil
+	if t.closer != nil {
+		return t.closer.Close()
+	}
+	return nil
+}
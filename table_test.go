@@ -0,0 +1,93 @@
+package rdx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	const n = 5000
+	var data []byte
+	half := 0
+	for i := 1; i <= n; i++ {
+		id := ID{Src: uint64(i % 7), Seq: uint64(i*2) << 6}
+		data = append(data, S(id, "value")...)
+		if i == n/2 {
+			half = len(data)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "test.rdxt")
+	file, err := os.Create(path)
+	assert.Nil(t, err)
+	w := NewTableWriter(file)
+	assert.Nil(t, w.Append(data[:half]))
+	assert.Nil(t, w.Append(data[half:]))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, ErrTableClosed, w.Append(data))
+	assert.Nil(t, file.Close())
+
+	r, err := OpenTableReader(path)
+	assert.Nil(t, err)
+	assert.Greater(t, r.Len(), 10)
+	var all []byte
+	for r.Read() {
+		all = append(all, r.Record()...)
+	}
+	assert.Nil(t, r.Error())
+	assert.Equal(t, data, all)
+
+	for i := 1; i <= n; i += 97 {
+		id := ID{Src: uint64(i % 7), Seq: uint64(i*2) << 6}
+		rec, err := r.Get(id)
+		assert.Nil(t, err)
+		assert.Equal(t, S(id, "value"), rec)
+		assert.Equal(t, Grtr, r.Seek(ID{Src: 0, Seq: uint64(i*2-1) << 6}))
+		assert.Equal(t, id, r.it.ID())
+		assert.True(t, r.Read())
+	}
+	_, err = r.Get(ID{Src: 1, Seq: 3 << 6})
+	assert.Equal(t, ErrRecordNotFound, err)
+	assert.Equal(t, Less, r.Seek(ID{Src: 0, Seq: (n*2 + 1) << 6}))
+	assert.Nil(t, r.Close())
+}
+
+func TestTableErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTableWriter(&buf)
+	w.BloomBits = 0
+	assert.Nil(t, w.Append(T(ID{1, 2 << 6}, "a")))
+	assert.Equal(t, ErrTableOrder, w.Append(T(ID{1, 2 << 6}, "b")))
+
+	buf.Reset()
+	w = NewTableWriter(&buf)
+	assert.Nil(t, w.Append(T(ID{1, 2 << 6}, "a")))
+	assert.Nil(t, w.Close())
+	table := buf.Bytes()
+	r, err := NewTableReader(bytes.NewReader(table), int64(len(table)))
+	assert.Nil(t, err)
+	rec, err := r.Get(ID{1, 2<<6 | 2})
+	assert.Nil(t, err)
+	assert.Equal(t, T(ID{1, 2 << 6}, "a"), rec)
+
+	_, err = NewTableReader(bytes.NewReader(table[1:]), int64(len(table)-1))
+	assert.Equal(t, ErrBadHeader, err)
+	trailer := table[len(table)-tableTrailerLen:]
+	index := table[binary.LittleEndian.Uint64(trailer[0:8]):]
+	index = index[:binary.LittleEndian.Uint32(trailer[8:12])]
+	assert.Nil(t, Validate(index))
+	hashes := binary.LittleEndian.Uint32(trailer[16:20])
+	binary.LittleEndian.PutUint32(trailer[16:20], 1<<30)
+	_, err = NewTableReader(bytes.NewReader(table), int64(len(table)))
+	assert.Equal(t, ErrBadHeader, err)
+	binary.LittleEndian.PutUint32(trailer[16:20], hashes)
+	table[4] ^= 1
+	r, err = NewTableReader(bytes.NewReader(table), int64(len(table)))
+	assert.Nil(t, err)
+	assert.False(t, r.Read())
+	assert.Equal(t, ErrTableChecksum, r.Error())
+}
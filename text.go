@@ -0,0 +1,195 @@
+package rdx
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Text is a collaborative string. A String element is an LWW register,
+// so concurrent edits overwrite each other; Text is a Linear of String
+// elements instead, one code point each, edited with DISCONT patches.
+// Concurrent inserts and deletes merge deterministically. A multi-rune
+// insert goes as an insertion train, so concurrent runs do not interleave.
+// Offsets are rune offsets unless said otherwise; non-String elements
+// count as empty. Remote patches get in with Merge.
+type Text struct {
+	ed *LinearEditor
+	// the live elements; edits update those in place,
+	// a merge makes them get read anew
+	cache []textRun
+	fresh bool
+}
+
+// NewText opens a text document; an empty doc starts a new text.
+func NewText(doc Stream, src uint64) (*Text, error) {
+	if len(doc) == 0 {
+		doc = WriteRDX(nil, LitLinear, ID0, nil)
+	}
+	ed, err := NewLinearEditor(doc, src)
+	if err != nil {
+		return nil, err
+	}
+	ed.trains = true
+	return &Text{ed: ed}, nil
+}
+
+// Doc returns the current version of the document.
+func (t *Text) Doc() Stream {
+	return t.ed.Doc()
+}
+
+// Merge merges a patch from another replica, or its whole document.
+func (t *Text) Merge(patch Stream) error {
+	t.fresh = false
+	return t.ed.Merge(patch)
+}
+
+// textRun is a live element: its ID and its string
+type textRun struct {
+	id  ID
+	str string
+}
+
+func (t *Text) runs() []textRun {
+	if t.fresh {
+		return t.cache
+	}
+	els, live := t.ed.elements()
+	t.cache = t.cache[:0]
+	for _, i := range live {
+		run := textRun{id: els[i].ID()}
+		if els[i].Lit() == LitString {
+			run.str = string(els[i].Value())
+		}
+		t.cache = append(t.cache, run)
+	}
+	t.fresh = true
+	return t.cache
+}
+
+func (t *Text) String() string {
+	var ret strings.Builder
+	for _, run := range t.runs() {
+		ret.WriteString(run.str)
+	}
+	return ret.String()
+}
+
+// Len returns the length of the text in runes.
+func (t *Text) Len() (n int) {
+	for _, run := range t.runs() {
+		n += utf8.RuneCountInString(run.str)
+	}
+	return
+}
+
+// position converts a rune offset into a live element position;
+// the offset must be at an element boundary
+func (t *Text) position(offset int) (pos int, err error) {
+	if offset < 0 {
+		return 0, ErrBadPosition
+	}
+	runs := t.runs()
+	for offset > 0 && pos < len(runs) {
+		offset -= utf8.RuneCountInString(runs[pos].str)
+		pos++
+	}
+	if offset != 0 {
+		return 0, ErrBadPosition
+	}
+	return
+}
+
+// Insert inserts a string at the rune offset, returns the patch.
+func (t *Text) Insert(offset int, str string) (patch Stream, err error) {
+	pos, err := t.position(offset)
+	if err != nil || len(str) == 0 {
+		return nil, err
+	}
+	elems := make([]Stream, 0, len(str))
+	for _, r := range str {
+		elems = append(elems, S0(string(r)))
+	}
+	patch, err = t.ed.InsertAt(pos, elems...)
+	if err != nil {
+		return nil, err
+	}
+	// the new elements end the patch, after the context
+	it := NewIter(patch)
+	it.Read()
+	in := it.Inner()
+	var ids []ID
+	for in.Read() {
+		ids = append(ids, in.ID())
+	}
+	runs := make([]textRun, 0, len(elems))
+	for i, r := range []rune(str) {
+		runs = append(runs, textRun{ids[len(ids)-len(elems)+i], string(r)})
+	}
+	t.cache = slices.Insert(t.cache, pos, runs...)
+	return patch, nil
+}
+
+// Delete removes length runes at the offset, returns the patch.
+func (t *Text) Delete(offset, length int) (patch Stream, err error) {
+	pos, err := t.position(offset)
+	if err != nil {
+		return nil, err
+	}
+	end, err := t.position(offset + length)
+	if err != nil {
+		return nil, err
+	}
+	patch, err = t.ed.DeleteRange(pos, end-pos)
+	if err == nil {
+		t.cache = slices.Delete(t.cache, pos, end)
+	}
+	return
+}
+
+// RuneID returns the ID of the element holding the rune at the offset.
+func (t *Text) RuneID(offset int) (ID, error) {
+	for _, run := range t.runs() {
+		n := utf8.RuneCountInString(run.str)
+		if offset >= 0 && offset < n {
+			return run.id, nil
+		}
+		offset -= n
+	}
+	return ID0, ErrBadPosition
+}
+
+// ByteID returns the ID of the element holding the UTF-8 byte offset.
+func (t *Text) ByteID(offset int) (ID, error) {
+	for _, run := range t.runs() {
+		if offset >= 0 && offset < len(run.str) {
+			return run.id, nil
+		}
+		offset -= len(run.str)
+	}
+	return ID0, ErrBadPosition
+}
+
+// Offset returns the rune offset of the element with the ID; an ID is
+// stable, so it can be used as a cursor while the text gets edited.
+func (t *Text) Offset(id ID) (offset int, err error) {
+	for _, run := range t.runs() {
+		if run.id.Base() == id.Base() {
+			return offset, nil
+		}
+		offset += utf8.RuneCountInString(run.str)
+	}
+	return 0, ErrRecordNotFound
+}
+
+// ByteOffset returns the UTF-8 byte offset of the element with the ID.
+func (t *Text) ByteOffset(id ID) (offset int, err error) {
+	for _, run := range t.runs() {
+		if run.id.Base() == id.Base() {
+			return offset, nil
+		}
+		offset += len(run.str)
+	}
+	return 0, ErrRecordNotFound
+}
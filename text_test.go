@@ -0,0 +1,147 @@
+package rdx
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestText(t *testing.T) {
+	text, err := NewText(nil, 1)
+	assert.Nil(t, err)
+	_, err = text.Insert(0, "Hello world")
+	assert.Nil(t, err)
+	_, err = text.Insert(5, ",")
+	assert.Nil(t, err)
+	_, err = text.Insert(12, "! Привет")
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello, world! Привет", text.String())
+	assert.Equal(t, 20, text.Len())
+	_, err = text.Delete(5, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello! Привет", text.String())
+
+	id, err := text.RuneID(8)
+	assert.Nil(t, err)
+	bid, err := text.ByteID(9)
+	assert.Nil(t, err)
+	assert.Equal(t, id, bid)
+	off, err := text.Offset(id)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, off)
+	off, err = text.ByteOffset(id)
+	assert.Nil(t, err)
+	assert.Equal(t, 9, off)
+	_, err = text.RuneID(13)
+	assert.Equal(t, ErrBadPosition, err)
+
+	replica, err := NewText(text.Doc(), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, text.String(), replica.String())
+}
+
+func TestTextConcurrent(t *testing.T) {
+	base, _ := NewText(nil, 1)
+	_, _ = base.Insert(0, "ab")
+	doc := base.Doc()
+	alice, _ := NewText(doc, 2)
+	bob, _ := NewText(doc, 3)
+	p1, err := alice.Insert(1, "xyz")
+	assert.Nil(t, err)
+	p2, err := bob.Insert(1, "123")
+	assert.Nil(t, err)
+	p3, err := bob.Delete(0, 1)
+	assert.Nil(t, err)
+
+	m1, err := Merge(nil, [][]byte{doc, p1, p2, p3})
+	assert.Nil(t, err)
+	m2, err := Merge(nil, [][]byte{doc, p3, p2, p1})
+	assert.Nil(t, err)
+	assert.Equal(t, m1, m2)
+	merged, err := NewText(m1, 4)
+	assert.Nil(t, err)
+	assert.Contains(t, []string{"xyz123b", "123xyzb"}, merged.String())
+}
+
+// TestTextRandom checks a long run of random edits against a plain string
+func TestTextRandom(t *testing.T) {
+	text, err := NewText(nil, 1)
+	assert.Nil(t, err)
+	doc := text.Doc()
+	words := []string{"a", "bc", "дом", "x y", "🙂", "long word"}
+	var ref []rune
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		var patch Stream
+		off := rnd.Intn(len(ref) + 1)
+		if i%10 == 0 {
+			off = 0 // prepends
+		}
+		if len(ref) > 0 && rnd.Intn(3) == 0 {
+			n := min(1+rnd.Intn(5), len(ref)-off)
+			patch, err = text.Delete(off, n)
+			ref = append(ref[:off], ref[off+n:]...)
+		} else {
+			word := []rune(words[rnd.Intn(len(words))])
+			patch, err = text.Insert(off, string(word))
+			ref = append(ref[:off], append(word, ref[off:]...)...)
+		}
+		if !assert.Nil(t, err, "step %d", i) {
+			break
+		}
+		doc, err = Merge(nil, [][]byte{doc, patch})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, string(ref), text.String())
+	assert.Equal(t, len(ref), text.Len())
+	replica, err := NewText(doc, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, string(ref), replica.String())
+}
+
+// TestTextConverge has two replicas edit at random, exchanging patches now
+// and then; both must end up with the same text
+func TestTextConverge(t *testing.T) {
+	alice, _ := NewText(nil, 2)
+	bob, _ := NewText(nil, 3)
+	replicas := []*Text{alice, bob}
+	pending := [2][]Stream{}
+	words := []string{"a", "bc", "дом", "🙂"}
+	rnd := rand.New(rand.NewSource(7))
+	for i := 0; i < 1000; i++ {
+		k := rnd.Intn(2)
+		text := replicas[k]
+		var patch Stream
+		var err error
+		if n := text.Len(); n > 0 && rnd.Intn(3) == 0 {
+			off := rnd.Intn(n)
+			patch, err = text.Delete(off, min(1+rnd.Intn(3), n-off))
+		} else {
+			patch, err = text.Insert(rnd.Intn(n+1), words[rnd.Intn(len(words))])
+		}
+		if !assert.Nil(t, err, "step %d", i) {
+			break
+		}
+		pending[1-k] = append(pending[1-k], patch)
+		if rnd.Intn(10) == 0 || i == 999 {
+			for j, text := range replicas {
+				for _, patch := range pending[j] {
+					assert.Nil(t, text.Merge(patch))
+				}
+				pending[j] = nil
+			}
+			assert.Equal(t, alice.String(), bob.String(), "step %d", i)
+			assert.Equal(t, alice.Doc(), bob.Doc(), "step %d", i)
+		}
+	}
+	fresh, err := NewText(alice.Doc(), 4)
+	assert.Nil(t, err)
+	assert.Equal(t, fresh.String(), alice.String())
+	assert.NotEmpty(t, alice.String())
+	over, err := ParseJDR([]byte(`"x"@bob-100`))
+	assert.Nil(t, err)
+	assert.Equal(t, ErrNotLinear, alice.Merge(over))
+	assert.NotNil(t, alice.Merge(Stream("i\x09")))
+	assert.Equal(t, fresh.String(), alice.String())
+}
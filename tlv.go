@@ -37,7 +37,7 @@ func ReadTLV(data []byte) (lit byte, value, rest []byte, err error) {
 			return
 		}
 		bl := binary.LittleEndian.Uint32(data[1:5])
-		if bl > MaxRecLen || int(bl) > len(data)+5 {
+		if bl > MaxRecLen || int(bl) > len(data)-5 {
 			if bl > MaxRecLen {
 				err = ErrBadRecord
 			} else {
@@ -0,0 +1,229 @@
+package rdx
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	ErrDuplicateSpot   = errors.New("duplicate spot")
+	ErrOverlongZip     = errors.New("overlong zip encoding")
+	ErrTooDeep         = errors.New("nesting is too deep")
+	ErrBadRecordLength = errors.New("bad record length")
+	ErrEmptyTuple      = errors.New("empty tuple in an Euler set")
+)
+
+// RDXError is a violation of the normal form found by Validate.
+// The rule is one of the sentinels: ErrBadOrder, ErrDuplicateSpot,
+// ErrOverlongZip, ErrTooDeep, ErrBadRecordLength, ErrBadRecord,
+// ErrEmptyTuple or a bad FIRST value error like ErrBadStringRecord.
+type RDXError struct {
+	// Offset is the start of the offending record in the stream
+	Offset int
+	// Path is the positions of the record and its parents
+	Path Path
+	Rule error
+}
+
+func (e *RDXError) Error() string {
+	return "rdx offset " + strconv.Itoa(e.Offset) + " path " +
+		e.Path.String() + ": " + e.Rule.Error()
+}
+
+func (e *RDXError) Unwrap() error {
+	return e.Rule
+}
+
+// Validate checks that the stream is in the normal form, i.e. Normalize
+// would return it unchanged: FIRST values are valid and minimally zipped,
+// records have minimal headers, Euler and Multix elements are ordered with
+// no duplicates, the nesting is within MaxNesting. It reads the data in
+// place, allocating nothing unless there is an error, an *RDXError.
+func Validate(data Stream) error {
+	if e := validate(data, 0, 0, 0); e != nil {
+		return e
+	}
+	return nil
+}
+
+// IsNormal tells whether the stream is normalized, see Validate.
+func IsNormal(data Stream) bool {
+	return validate(data, 0, 0, 0) == nil
+}
+
+// readHeader parses a TLV header, checking the length is minimal
+func readHeader(data []byte) (lit byte, hlen, vlen int, err error) {
+	lit = data[0]
+	switch {
+	case lit >= 'a' && lit <= 'z':
+		if len(data) < 2 {
+			return 0, 0, 0, ErrBadRecordLength
+		}
+		lit, hlen, vlen = lit-CaseBit, 2, int(data[1])
+	case lit >= 'A' && lit <= 'Z':
+		if len(data) < 5 {
+			return 0, 0, 0, ErrBadRecordLength
+		}
+		bl := binary.LittleEndian.Uint32(data[1:5])
+		if bl <= 0xff || bl > MaxRecLen {
+			return 0, 0, 0, ErrBadRecordLength
+		}
+		hlen, vlen = 5, int(bl)
+	default:
+		return 0, 0, 0, ErrBadRecord
+	}
+	if vlen > len(data)-hlen || vlen == 0 || int(data[hlen]) >= vlen {
+		return 0, 0, 0, ErrBadRecordLength
+	}
+	if !IsFIRST(lit) && !IsPLEX(lit) {
+		return 0, 0, 0, ErrBadRecord
+	}
+	return
+}
+
+// zipPairLen is the length of ZipUint64Pair(big, lil)
+func zipPairLen(big, lil uint64) int {
+	switch (byteLen(big) << 4) | byteLen(lil) {
+	case 0x00:
+		return 0
+	case 0x10:
+		return 1
+	case 0x01, 0x11:
+		return 2
+	case 0x20, 0x21:
+		return 3
+	case 0x02, 0x12, 0x22:
+		return 4
+	case 0x40, 0x41:
+		return 5
+	case 0x42:
+		return 6
+	case 0x04, 0x14, 0x24, 0x44:
+		return 8
+	case 0x80, 0x81:
+		return 9
+	case 0x82:
+		return 10
+	case 0x08, 0x18, 0x28:
+		return 11
+	case 0x84:
+		return 12
+	case 0x48:
+		return 13
+	default:
+		return 16
+	}
+}
+
+// isZipPair checks a zipped pair is minimal, gaps included
+func isZipPair(zip []byte) bool {
+	switch len(zip) {
+	case 11:
+		if zip[2] != 0 {
+			return false
+		}
+	case 13:
+		if zip[4] != 0 {
+			return false
+		}
+	}
+	return zipPairLen(UnzipUint64Pair(zip)) == len(zip)
+}
+
+// isZipUint checks a zipped uint64 is minimal
+func isZipUint(zip []byte) bool {
+	return len(zip) <= 8 && (len(zip) == 0 || zip[len(zip)-1] != 0)
+}
+
+func validateFIRST(lit byte, val []byte) error {
+	switch lit {
+	case LitFloat:
+		if len(val) > 8 || math.IsNaN(UnzipFloat64(val)) {
+			return ErrBadFloatRecord
+		}
+		if !isZipUint(val) {
+			return ErrOverlongZip
+		}
+	case LitInteger:
+		if len(val) > 8 {
+			return ErrBadIntegerRecord
+		}
+		if !isZipUint(val) {
+			return ErrOverlongZip
+		}
+	case LitReference:
+		if len(val) > 16 {
+			return ErrBadReferenceRecord
+		}
+		if !isZipPair(val) {
+			return ErrOverlongZip
+		}
+	case LitString:
+		if !utf8.Valid(val) {
+			return ErrBadStringRecord
+		}
+	case LitTerm:
+		for _, c := range val {
+			if RON64REV[c] == 0xff {
+				return ErrBadTermRecord
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the records of a stream or a PLEX body; off is the
+// offset of the data in the whole stream, plit is the container type
+func validate(data []byte, off int, plit byte, depth int) *RDXError {
+	var prev Iter
+	for n, pos := 0, 0; pos < len(data); n++ {
+		lit, hlen, vlen, err := readHeader(data[pos:])
+		if err != nil {
+			return &RDXError{Offset: off + pos, Path: Path{n}, Rule: err}
+		}
+		rec := data[pos : pos+hlen+vlen]
+		klen := int(rec[hlen])
+		val := rec[hlen+1+klen:]
+		if klen > 16 {
+			err = ErrBadRecordLength
+		} else if !isZipPair(rec[hlen+1 : hlen+1+klen]) {
+			err = ErrOverlongZip
+		} else if IsFIRST(lit) {
+			err = validateFIRST(lit, val)
+		} else if depth >= MaxNesting {
+			err = ErrTooDeep
+		} else if lit == LitTuple && plit == LitEuler && len(val) == 0 {
+			err = ErrEmptyTuple
+		}
+		if err == nil && n > 0 && (plit == LitEuler || plit == LitMultix) {
+			it := NewIter(rec)
+			it.Read()
+			c := CompareMultix(&prev, &it)
+			if plit == LitEuler {
+				c = CompareEuler(&prev, &it)
+			}
+			if c == Eq {
+				err = ErrDuplicateSpot
+			} else if c > Eq {
+				err = ErrBadOrder
+			}
+		}
+		if err != nil {
+			return &RDXError{Offset: off + pos, Path: Path{n}, Rule: err}
+		}
+		if IsPLEX(lit) {
+			voff := off + pos + hlen + 1 + klen
+			if e := validate(val, voff, lit, depth+1); e != nil {
+				e.Path = append(Path{n}, e.Path...)
+				return e
+			}
+		}
+		prev = NewIter(rec)
+		prev.Read()
+		pos += hlen + vlen
+	}
+	return nil
+}
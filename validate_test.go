@@ -0,0 +1,83 @@
+package rdx
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	docs := []string{
+		"{a:1, b:(2 3), c:[x y z]} \"str\"@alice-4 1.5 -7",
+		"<1@alice-2 2@bob-4> [1 2 3] {1 2 3}",
+		"{(1 \"one\") (2 \"two\")} ()",
+		"",
+	}
+	for _, doc := range docs {
+		norm, err := ParseNormalizeJDR([]byte(doc))
+		assert.Nil(t, err, doc)
+		assert.Nil(t, Validate(norm), doc)
+		assert.True(t, IsNormal(norm), doc)
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+			_ = IsNormal(norm)
+		}), doc)
+	}
+
+	bad := []struct {
+		jdr  string
+		rule error
+		path Path
+	}{
+		{"{3 1 2}", ErrBadOrder, Path{0, 1}},
+		{"1 {a:1, a:2}", ErrDuplicateSpot, Path{1, 1}},
+		{"<1@carol-2 2@bob-4>", ErrBadOrder, Path{0, 1}},
+		{"<1@bob-4 2@bob-8>", ErrDuplicateSpot, Path{0, 1}},
+		{"[{1, (), 2}]", ErrEmptyTuple, Path{0, 0, 1}},
+	}
+	for _, b := range bad {
+		parsed, err := ParseJDR([]byte(b.jdr))
+		assert.Nil(t, err, b.jdr)
+		err = Validate(parsed)
+		assert.ErrorIs(t, err, b.rule, b.jdr)
+		var re *RDXError
+		if assert.True(t, errors.As(err, &re), b.jdr) {
+			assert.Equal(t, b.path, re.Path, b.jdr)
+		}
+		norm, _ := Normalize(parsed)
+		assert.False(t, bytes.Equal(norm, parsed), b.jdr)
+		assert.True(t, IsNormal(norm), b.jdr)
+	}
+
+	// over-long zip: 1 as two bytes
+	overlong := Stream{'i', 3, 0, 2, 0}
+	err := Validate(overlong)
+	assert.ErrorIs(t, err, ErrOverlongZip)
+	norm, _ := Normalize(overlong)
+	assert.True(t, IsNormal(norm))
+	// a long header for a short record
+	long := Stream{'I', 2, 0, 0, 0, 0, 2}
+	assert.ErrorIs(t, Validate(long), ErrBadRecordLength)
+	// a record longer than the data, inside a tuple
+	cut := Stream{'p', 4, 0, 'i', 3, 0}
+	err = Validate(cut)
+	assert.ErrorIs(t, err, ErrBadRecordLength)
+	var re *RDXError
+	assert.True(t, errors.As(err, &re))
+	assert.Equal(t, 3, re.Offset)
+	assert.Equal(t, Path{0, 0}, re.Path)
+	// NaN, bad UTF-8
+	nan := WriteRDX(nil, LitFloat, ID0, ZipFloat64(math.NaN()))
+	assert.ErrorIs(t, Validate(nan), ErrBadFloatRecord)
+	assert.ErrorIs(t, Validate(WriteRDX(nil, LitString, ID0, []byte{0xff})), ErrBadStringRecord)
+	// too deep
+	deep := Stream(nil)
+	for i := 0; i < MaxNesting; i++ {
+		deep = WriteRDX(nil, LitTuple, ID0, deep)
+	}
+	assert.Nil(t, Validate(deep))
+	deep = WriteRDX(nil, LitTuple, ID0, deep)
+	assert.ErrorIs(t, Validate(deep), ErrTooDeep)
+}
@@ -0,0 +1,176 @@
+package rdx
+
+import "errors"
+
+// VV is a version vector: the maximum seen Seq per Src.
+type VV map[uint64]uint64
+
+var ErrBadVV = errors.New("bad version vector")
+
+// VersionOf returns the version vector of all the stamps in a document.
+func VersionOf(rdx Stream) VV {
+	vv := make(VV)
+	vv.addAll(rdx)
+	return vv
+}
+
+func (vv VV) addAll(rdx Stream) {
+	it := NewIter(rdx)
+	for it.Read() {
+		vv.See(it.ID())
+		if IsPLEX(it.Lit()) {
+			vv.addAll(it.Value())
+		}
+	}
+}
+
+// See adds an ID to the vector.
+func (vv VV) See(id ID) {
+	if id.IsZero() {
+		return
+	}
+	if id.Seq > vv[id.Src] {
+		vv[id.Src] = id.Seq
+	}
+}
+
+// Covers reports whether the vector includes the ID.
+// The zero ID is always covered.
+func (vv VV) Covers(id ID) bool {
+	return vv[id.Src] >= id.Seq
+}
+
+// Merge adds everything the other vector has seen.
+func (vv VV) Merge(b VV) {
+	for src, seq := range b {
+		if seq > vv[src] {
+			vv[src] = seq
+		}
+	}
+}
+
+// Dominates reports whether the vector covers everything b has seen.
+func (vv VV) Dominates(b VV) bool {
+	for src, seq := range b {
+		if vv[src] < seq {
+			return false
+		}
+	}
+	return true
+}
+
+// Multix encodes the vector as a Multix of Integers, e.g. <5@alice 3@bob>;
+// the Src goes into the stamp, the Seq goes into the value. Merging such
+// Multix elements merges the vectors.
+func (vv VV) Multix() Stream {
+	vals := make([]Stream, 0, len(vv))
+	for src, seq := range vv {
+		vals = append(vals, I(ID{Src: src}, Integer(seq)))
+	}
+	norm, _ := Normalize(MakePLEXOf(LitMultix, ID0, vals, nil))
+	return norm
+}
+
+// ParseVV decodes a version vector encoded by VV.Multix.
+func ParseVV(rdx Stream) (vv VV, err error) {
+	it := NewIter(rdx)
+	if !it.Read() || it.Lit() != LitMultix {
+		return nil, ErrBadVV
+	}
+	vv = make(VV)
+	in := it.Inner()
+	for in.Read() {
+		if in.Lit() != LitInteger || in.Integer() < 0 {
+			return nil, ErrBadVV
+		}
+		vv.See(ID{Src: in.ID().Src, Seq: uint64(in.Integer())})
+	}
+	if in.HasFailed() {
+		return nil, in.Error()
+	}
+	return
+}
+
+// Since returns the part of a normalized document that is newer than
+// the version vector: new elements with all their contents, plus the
+// enclosing containers. Merged into a replica at version vv, it produces
+// the document.
+func Since(doc Stream, vv VV) (delta Stream, err error) {
+	return sinceTuple(nil, doc, vv, false)
+}
+
+func sinceElement(data []byte, it *Iter, vv VV, plit byte) (delta []byte, err error) {
+	if !vv.Covers(it.ID()) {
+		return append(data, it.Record()...), nil
+	}
+	lit := it.Lit()
+	if !IsPLEX(lit) {
+		return data, nil
+	}
+	stack := make(Marks, 0, 1)
+	delta = OpenTLV(data, lit, &stack)
+	zip := ZipID(it.ID())
+	delta = append(delta, byte(len(zip)))
+	delta = append(delta, zip...)
+	l := len(delta)
+	if lit == LitTuple {
+		delta, err = sinceTuple(delta, it.Value(), vv, plit == LitEuler)
+	} else {
+		in := it.Inner()
+		for in.Read() && err == nil {
+			delta, err = sinceElement(delta, &in, vv, lit)
+		}
+		if err == nil && in.HasFailed() {
+			err = in.Error()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(delta) == l {
+		return CancelTLV(delta, lit, &stack)
+	}
+	return CloseTLV(delta, lit, &stack)
+}
+
+// sinceTuple keeps the positions with () placeholders;
+// the key of a map entry is always cited
+func sinceTuple(data, rdx []byte, vv VV, keyed bool) (delta []byte, err error) {
+	delta = data
+	trim := len(delta)
+	it := NewIter(rdx)
+	for n := 0; it.Read() && err == nil; n++ {
+		l := len(delta)
+		delta, err = sinceElement(delta, &it, vv, LitTuple)
+		if len(delta) != l {
+			trim = len(delta)
+		} else if keyed && n == 0 {
+			delta = append(delta, it.Record()...)
+		} else {
+			delta = append(delta, RDXEmptyTuple...)
+		}
+	}
+	if err == nil && it.HasFailed() {
+		err = it.Error()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return delta[:trim], nil
+}
+
+// Compact strips the tombstones the stable version vector covers: those
+// keep their IDs and types, but lose their values. Every replica has seen
+// such a deletion, so nothing needs the value anymore. The tombstone
+// itself can not go: a replica may yet send a write it made concurrently
+// with the deletion (one from before the frontier, e.g. a lower revision
+// of the same element), and only the tombstone outranks it. Without the
+// tombstone, the stale write would come back to life. Map keys and Euler
+// set members keep their values, those are identities; so do Terms, as an
+// empty Term has no JDR form. Deleted containers keep their contents,
+// see compactTombstones; their own tombstones get stripped.
+// Live elements keep their stamps, unlike Flatten.
+// MergeOperator compacts the same way, covering all the stamps.
+func Compact(doc Stream, stable VV) (gc Stream, err error) {
+	return compactTombstones(nil, doc, LitTuple, false, stable.Covers)
+}
@@ -0,0 +1,137 @@
+package rdx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionOf(t *testing.T) {
+	doc, err := ParseNormalizeJDR([]byte("{@alice-4 a:1@bob-2, b:[x@alice-8 y@carol-3]}"))
+	assert.Nil(t, err)
+	vv := VersionOf(doc)
+	alice, _ := NewID([]byte("alice-8"))
+	bob, _ := NewID([]byte("bob-2"))
+	carol, _ := NewID([]byte("carol-3"))
+	assert.Equal(t, VV{alice.Src: alice.Seq, bob.Src: bob.Seq, carol.Src: carol.Seq}, vv)
+	assert.True(t, vv.Covers(bob))
+	assert.True(t, vv.Covers(ID0))
+	assert.False(t, vv.Covers(ID{bob.Src, bob.Seq + 1}))
+
+	other := VV{bob.Src: bob.Seq + 2}
+	assert.False(t, vv.Dominates(other))
+	assert.False(t, other.Dominates(vv))
+	other.Merge(vv)
+	assert.True(t, other.Dominates(vv))
+	assert.Equal(t, bob.Seq+2, other[bob.Src])
+
+	back, err := ParseVV(vv.Multix())
+	assert.Nil(t, err)
+	assert.Equal(t, vv, back)
+	merged, err := Merge(nil, [][]byte{vv.Multix(), other.Multix()})
+	assert.Nil(t, err)
+	back, err = ParseVV(merged)
+	assert.Nil(t, err)
+	assert.Equal(t, other, back)
+	_, err = ParseVV(I0(1))
+	assert.Equal(t, ErrBadVV, err)
+}
+
+func TestSince(t *testing.T) {
+	cases := [][3]string{
+		{"{a:1, b:2}", "{a:1, b:3@bob-2}", "{(b 3@bob-2)}"},
+		{"{@alice-4 a:1, b:{x:1}}", "{@alice-4 a:1, b:{x:1, y:2@bob-2}}", "{@alice-4 (b {(y 2@bob-2)})}"},
+		{"(1 2 3)", "(1 5@bob-2 3)", "(() 5@bob-2)"},
+		{"[a@alice-10 b@alice-30]", "[a@alice-10 c@bob-20 b@alice-30]", "[c@bob-20]"},
+		{"[a@alice-10 b@alice-30]", "[a@alice-10 b@alice-31]", "[b@alice-31]"},
+		{"<1@alice-2>", "<1@alice-2 2@bob-2>", "<2@bob-2>"},
+		{"1 2", "1 2 3@bob-2", "() () 3@bob-2"},
+	}
+	for _, c := range cases {
+		old, err := ParseNormalizeJDR([]byte(c[0]))
+		assert.Nil(t, err)
+		doc, err := ParseNormalizeJDR([]byte(c[1]))
+		assert.Nil(t, err)
+		delta, err := Since(doc, VersionOf(old))
+		assert.Nil(t, err)
+		assert.Equal(t, c[2], string(RenderJDR(delta, StyleStamps)), c[1])
+		merged, err := Merge(nil, [][]byte{old, delta})
+		assert.Nil(t, err)
+		assert.Equal(t, string(RenderJDR(doc, StyleStamps)),
+			string(RenderJDR(merged, StyleStamps)), c[1])
+		delta, err = Since(doc, VersionOf(doc))
+		assert.Nil(t, err)
+		assert.Empty(t, delta, c[1])
+	}
+	_, err := Since(Stream("i\x09"), VV{})
+	assert.NotNil(t, err)
+}
+
+func TestCompact(t *testing.T) {
+	cases := [][2]string{
+		{"{a:1, b:2@bob-3, c:{@alice-5 x:1}, (@bob-5 d 4)}", "{(a 1) (b 0@bob-3) (c {@alice-5 (x 1)}) (@bob-5 d 4)}"},
+		{"(1 2@bob-3 3 4@bob-5)", "(1 0@bob-3 3 0@bob-5)"},
+		{"[a@alice-10 \"c\"@alice-21 b@alice-31]", "[a@alice-10 \"\"@alice-21 b@alice-31]"},
+		{"<1@alice-2 2@bob-3>", "<0@bob-3 1@alice-2>"},
+		{"{1 2@bob-3 {@alice-4 3@bob-5 4}}", "{1 2@bob-3 {@alice-4 3@bob-5 4}}"},
+		{"{[@bob-5 1 2] (@bob-5 k v)}", "{(@bob-5 k v) [@bob-5 1 2]}"},
+		{"1 2@bob-3 3", "1 0@bob-3 3"},
+		{"1 2@carol-3", "1 2@carol-3"},
+	}
+	stable := make(VV)
+	for _, id := range []string{"alice-40", "bob-6"} {
+		sid, err := NewID([]byte(id))
+		assert.Nil(t, err)
+		stable.See(sid)
+	}
+	for _, c := range cases {
+		doc, err := ParseNormalizeJDR([]byte(c[0]))
+		assert.Nil(t, err)
+		gc, err := Compact(doc, stable)
+		assert.Nil(t, err)
+		assert.Equal(t, c[1], string(RenderJDR(gc, StyleStamps)), c[0])
+		assert.Nil(t, Validate(gc), c[0])
+		back, err := ParseNormalizeJDR([]byte(c[1]))
+		assert.Nil(t, err, c[1])
+		assert.Equal(t, gc, back, c[1])
+		again, err := Compact(gc, stable)
+		assert.Nil(t, err)
+		assert.Equal(t, gc, again, c[0])
+	}
+	_, err := Compact(Stream("i\x09"), stable)
+	assert.NotNil(t, err)
+
+	// patches merge the same, concurrent ones included
+	merges := [][2]string{
+		{`{a:1, b:2@bob-3, (@bob-5 d 4), s:{1 2@bob-3}}`, `{a:5@carol-2}`},
+		{`{a:1, b:2@bob-3, (@bob-5 d 4), s:{1 2@bob-3}}`, `{b:6@carol-8}`},
+		{`{a:1, b:2@bob-3, (@bob-5 d 4), s:{1 2@bob-3}}`, `{(@carol-8 d 7)}`},
+		{`{a:1, b:2@bob-3, (@bob-5 d 4), s:{1 2@bob-3}}`, `{s:{2@carol-8 3@carol-2}}`},
+		{`{a:1, b:2@bob-3, (@bob-5 d 4), s:{1 2@bob-3}}`, `{e:{@carol-2 x:1}}`},
+		{`{a:1, b:2@bob-3}`, `{b:6@carol-2}`},
+		{`{1 2@bob-3}`, `{2@carol-2}`},
+		{`(1 2@bob-3 3)`, `(1 7@carol-2 3)`},
+		{`[a@alice-10 b@alice-31]`, `[a@alice-10 b@carol-2]`},
+		{`{(@bob-5 d 4)}`, `{(@carol-2 d 5)}`},
+		{`{c:{@alice-5 x:1}}`, `{c:{@carol-8 y:2}}`},
+		{`{[@bob-5 1 2]}`, `{[@carol-8 3]}`},
+	}
+	for _, c := range merges {
+		doc, err := ParseNormalizeJDR([]byte(c[0]))
+		assert.Nil(t, err)
+		patch, err := ParseNormalizeJDR([]byte(c[1]))
+		assert.Nil(t, err)
+		gc, err := Compact(doc, stable)
+		assert.Nil(t, err)
+		full, err := Merge(nil, [][]byte{doc, patch})
+		assert.Nil(t, err)
+		part, err := Merge(nil, [][]byte{gc, patch})
+		assert.Nil(t, err)
+		want, err := Compact(full, stable)
+		assert.Nil(t, err)
+		got, err := Compact(part, stable)
+		assert.Nil(t, err)
+		assert.Equal(t, string(RenderJDR(want, StyleStamps)),
+			string(RenderJDR(got, StyleStamps)), c[1])
+	}
+}
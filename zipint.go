@@ -29,64 +29,69 @@ func byteLen(n uint64) int {
 // ZipUint64Pair packs a pair of uint64 into a byte string.
 // The smaller the ints, the shorter the string TODO 4+3 etc
 func ZipUint64Pair(big, lil uint64) []byte {
-	var ret = [16]byte{}
+	var ret [16]byte
+	return ret[:putUint64Pair(&ret, big, lil)]
+}
+
+// putUint64Pair zips a pair into the array, returns the length
+func putUint64Pair(ret *[16]byte, big, lil uint64) int {
 	pat := (byteLen(big) << 4) | byteLen(lil)
 	switch pat {
 	case 0x00:
-		return ret[0:0]
+		return 0
 	case 0x10:
 		ret[0] = byte(big)
-		return ret[0:1]
+		return 1
 	case 0x01, 0x11: // 2
 		ret[0] = byte(big)
 		ret[1] = byte(lil)
-		return ret[0:2]
+		return 2
 	case 0x20, 0x21:
 		binary.LittleEndian.PutUint16(ret[0:2], uint16(big))
 		ret[2] = byte(lil)
-		return ret[0:3]
+		return 3
 	case 0x02, 0x12, 0x22:
 		binary.LittleEndian.PutUint16(ret[0:2], uint16(big))
 		binary.LittleEndian.PutUint16(ret[2:4], uint16(lil))
-		return ret[0:4]
+		return 4
 	case 0x40, 0x41:
 		binary.LittleEndian.PutUint32(ret[0:4], uint32(big))
 		ret[4] = byte(lil)
-		return ret[0:5]
+		return 5
 	case 0x42:
 		binary.LittleEndian.PutUint32(ret[0:4], uint32(big))
 		binary.LittleEndian.PutUint16(ret[4:6], uint16(lil))
-		return ret[0:6]
+		return 6
 	case 0x04, 0x14, 0x24, 0x44:
 		binary.LittleEndian.PutUint32(ret[0:4], uint32(big))
 		binary.LittleEndian.PutUint32(ret[4:8], uint32(lil))
-		return ret[0:8]
+		return 8
 	case 0x80, 0x81:
 		binary.LittleEndian.PutUint64(ret[0:8], big)
 		ret[8] = byte(lil)
-		return ret[0:9]
+		return 9
 	case 0x82:
 		binary.LittleEndian.PutUint64(ret[0:8], big)
 		binary.LittleEndian.PutUint16(ret[8:10], uint16(lil))
-		return ret[0:10]
+		return 10
 	case 0x84:
 		binary.LittleEndian.PutUint64(ret[0:8], big)
 		binary.LittleEndian.PutUint32(ret[8:12], uint32(lil))
-		return ret[0:12]
+		return 12
 	case 0x08, 0x18, 0x28:
 		binary.LittleEndian.PutUint16(ret[0:2], uint16(big))
 		binary.LittleEndian.PutUint64(ret[3:11], lil)
-		return ret[0:11]
+		return 11
 	case 0x48:
 		binary.LittleEndian.PutUint32(ret[0:4], uint32(big))
 		binary.LittleEndian.PutUint64(ret[5:13], lil)
-		return ret[0:13]
+		return 13
 	case 0x88:
 		binary.LittleEndian.PutUint64(ret[0:8], big)
 		binary.LittleEndian.PutUint64(ret[8:16], lil)
-		return ret[0:16]
+		return 16
 	}
-	return ret[:]
+	return 16
 }
 
 func UnzipUint64Pair(buf []byte) (big, lil uint64) {
@@ -175,14 +180,17 @@ func Uint32Unpair(x uint64) (a, b uint32) {
 
 // ZipUint64 packs uint64 into a shortest possible byte string
 func ZipUint64(v uint64) []byte {
-	buf := [8]byte{}
-	i := 0
+	var buf [8]byte
+	return buf[:putUint64(&buf, v)]
+}
+
+func putUint64(buf *[8]byte, v uint64) (i int) {
 	for v > 0 {
 		buf[i] = uint8(v)
 		v >>= 8
 		i++
 	}
-	return buf[0:i]
+	return
 }
 
 func UnzipUint64(zip []byte) (v uint64) {